@@ -5,12 +5,28 @@ type GlobalFlags struct {
 	Tag         string
 	HttpProxy   string
 	GithubProxy string
+	Options     map[string]string
+	// 同时执行的任务数, 小于等于1时顺序执行
+	Jobs int
+	// 跳过下载文件的SHA256校验
+	SkipVerify bool
 }
 
 // Define an interface for managing applications
 type Manager interface {
 	GetName() string
+	GetMetadata() *Metadata
+	IsInstalled() bool
 	Install(flags *GlobalFlags) error
 	Update(flags *GlobalFlags) error
 	Delete(flags *GlobalFlags) error
+	Status(flags *GlobalFlags) *AppStatus
+}
+
+// GetOption 返回应用的自定义选项, 未设置时返回默认值
+func (f *GlobalFlags) GetOption(key, defaultValue string) string {
+	if value, ok := f.Options[key]; ok && value != "" {
+		return value
+	}
+	return defaultValue
 }
@@ -0,0 +1,96 @@
+package app
+
+// 内置的Release工具, 新增工具时在这里添加定义即可
+var releaseBinaries = []*ReleaseBinary{
+	{
+		Name:        "chsrc",
+		Description: "一个全平台的命令行换源工具",
+		Owner:       "RubyMetric",
+		Repo:        "chsrc",
+		DefaultTag:  "v0.1.8",
+		OS:          []string{"linux", "darwin"},
+		Arch:        []string{"amd64", "arm64"},
+		OSNames:     map[string][]string{"darwin": {"macos"}},
+		ArchNames:   map[string][]string{"amd64": {"x64"}, "arm64": {"aarch64"}},
+		Exclude:     []string{".exe"},
+		Assets: map[string]string{
+			"linux/amd64":  "chsrc-x64-linux",
+			"linux/arm64":  "chsrc-aarch64-linux",
+			"darwin/amd64": "chsrc-x64-macos",
+			"darwin/arm64": "chsrc-aarch64-macos",
+		},
+		// Release中直接发布可执行文件
+		Format: binaryFormat,
+	},
+	{
+		Name:        "fzf",
+		Description: "命令行模糊查找工具",
+		Owner:       "junegunn",
+		Repo:        "fzf",
+		DefaultTag:  "v0.56.3",
+		OS:          []string{"linux", "darwin"},
+		Arch:        []string{"amd64", "arm64"},
+		Suffixes:    []string{".tar.gz", ".zip"},
+		Assets: map[string]string{
+			"linux/amd64":  "fzf-{version}-linux_amd64.tar.gz",
+			"linux/arm64":  "fzf-{version}-linux_arm64.tar.gz",
+			"darwin/amd64": "fzf-{version}-darwin_amd64.tar.gz",
+			"darwin/arm64": "fzf-{version}-darwin_arm64.tar.gz",
+		},
+	},
+	{
+		Name:        "ripgrep",
+		Description: "按正则表达式递归搜索目录的工具(rg)",
+		Owner:       "BurntSushi",
+		Repo:        "ripgrep",
+		DefaultTag:  "14.1.1",
+		OS:          []string{"linux", "darwin"},
+		Arch:        []string{"amd64", "arm64"},
+		Suffixes:    []string{".tar.gz"},
+		Assets: map[string]string{
+			"linux/amd64":  "ripgrep-{version}-x86_64-unknown-linux-musl.tar.gz",
+			"linux/arm64":  "ripgrep-{version}-aarch64-unknown-linux-gnu.tar.gz",
+			"darwin/amd64": "ripgrep-{version}-x86_64-apple-darwin.tar.gz",
+			"darwin/arm64": "ripgrep-{version}-aarch64-apple-darwin.tar.gz",
+		},
+		Bin: "rg",
+	},
+	{
+		Name:        "bat",
+		Description: "支持语法高亮和Git集成的cat",
+		Owner:       "sharkdp",
+		Repo:        "bat",
+		DefaultTag:  "v0.25.0",
+		OS:          []string{"linux", "darwin"},
+		Arch:        []string{"amd64", "arm64"},
+		Suffixes:    []string{".tar.gz"},
+		Assets: map[string]string{
+			"linux/amd64":  "bat-{tag}-x86_64-unknown-linux-gnu.tar.gz",
+			"linux/arm64":  "bat-{tag}-aarch64-unknown-linux-gnu.tar.gz",
+			"darwin/amd64": "bat-{tag}-x86_64-apple-darwin.tar.gz",
+			"darwin/arm64": "bat-{tag}-aarch64-apple-darwin.tar.gz",
+		},
+	},
+	{
+		Name:        "lazygit",
+		Description: "Git的终端界面",
+		Owner:       "jesseduffield",
+		Repo:        "lazygit",
+		DefaultTag:  "v0.44.1",
+		OS:          []string{"linux", "darwin"},
+		Arch:        []string{"amd64", "arm64"},
+		Suffixes:    []string{".tar.gz"},
+		Assets: map[string]string{
+			"linux/amd64":  "lazygit_{version}_Linux_x86_64.tar.gz",
+			"linux/arm64":  "lazygit_{version}_Linux_arm64.tar.gz",
+			"darwin/amd64": "lazygit_{version}_Darwin_x86_64.tar.gz",
+			"darwin/arm64": "lazygit_{version}_Darwin_arm64.tar.gz",
+		},
+	},
+}
+
+func init() {
+	for _, def := range releaseBinaries {
+		RegisterReleaseBinary(def)
+	}
+}
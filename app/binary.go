@@ -0,0 +1,409 @@
+package app
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// ReleaseBinary 描述一个从Release下载可执行文件安装的命令行工具,
+// 新增此类工具只需要添加一份定义, 不需要编写新的管理器
+type ReleaseBinary struct {
+	Name        string
+	Description string
+	// 主页, 为空时为仓库地址
+	Homepage string
+	// 仓库所在的代码平台, 即配置文件 sources 中的名称, 为空时为GitHub
+	Source       string
+	Owner        string
+	Repo         string
+	DefaultTag   string
+	OS           []string
+	Arch         []string
+	Dependencies []string
+	After        []string
+	// 操作系统和架构在Release文件名中的写法, 未指定时使用常见写法
+	OSNames   map[string][]string
+	ArchNames map[string][]string
+	// 允许的文件后缀, 匹配到多个文件时优先选择靠前的后缀
+	Suffixes []string
+	// 文件名中包含这些内容时忽略
+	Exclude []string
+	// 各平台的Release文件名模板, 键为 "os/arch", 可以使用 {tag} 和 {version}(去掉前缀v的版本号)。
+	// 仅在无法获取Release信息时使用
+	Assets map[string]string
+	// 下载文件的格式: 为空时根据文件名判断, "binary" 表示文件本身就是可执行文件, 其他值为压缩格式, 如 "tar.gz"、"zip"
+	Format string
+	// 可执行文件在压缩包中的路径, 可以使用 {tag} 和 {version}; 为空时在压缩包中查找名为 Bin 的文件
+	Path string
+	// 安装后的命令名, 默认与 Name 相同
+	Bin string
+	// 安装目录, 默认为 /usr/local/bin, ~ 表示用户主目录
+	InstallDir string
+	// 查询已安装版本的命令, 默认为 "<Bin> --version"
+	VersionCmd string
+	// 内置的SHA256, 键为 "tag/文件名"。没有内置哈希时依赖GitHub API返回的文件摘要校验
+	Checksums map[string]string
+}
+
+// 以 binary 为格式时不解压下载的文件
+const binaryFormat = "binary"
+
+// 可以解压的文件后缀
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.bz2", ".tbz2", ".tar.zst", ".tar", ".zip"}
+
+func (b *ReleaseBinary) binName() string {
+	if b.Bin != "" {
+		return b.Bin
+	}
+	return b.Name
+}
+
+// expand 替换模板中的 {tag} 和 {version}
+func (b *ReleaseBinary) expand(tmpl, tagName string) string {
+	return strings.NewReplacer("{tag}", tagName, "{version}", strings.TrimPrefix(tagName, "v")).Replace(tmpl)
+}
+
+func (b *ReleaseBinary) assetRule() *utils.AssetRule {
+	rule := &utils.AssetRule{
+		OS:       b.OSNames,
+		Arch:     b.ArchNames,
+		Suffixes: b.Suffixes,
+		Exclude:  b.Exclude,
+	}
+	if len(b.Assets) > 0 {
+		rule.Fallback = func(tagName, osType, arch string) string {
+			return b.expand(b.Assets[osType+"/"+arch], tagName)
+		}
+	}
+	return rule
+}
+
+// isArchive 判断下载的文件是否需要解压
+func (b *ReleaseBinary) isArchive(srcFileName string) bool {
+	switch b.Format {
+	case "":
+		return hasArchiveSuffix(srcFileName)
+	case binaryFormat:
+		return false
+	default:
+		return true
+	}
+}
+
+func hasArchiveSuffix(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReleaseBinaryManager 按照 ReleaseBinary 的定义安装、更新和删除工具
+type ReleaseBinaryManager struct {
+	def    *ReleaseBinary
+	config *config.Config
+	// postInstall 在安装可执行文件后执行额外的配置, 可以向rec中追加生成的文件
+	postInstall func(flags *GlobalFlags, tx *utils.Transaction, rec *state.Record) error
+}
+
+func NewReleaseBinaryManager(def *ReleaseBinary, config *config.Config) *ReleaseBinaryManager {
+	return &ReleaseBinaryManager{def: def, config: config}
+}
+
+// RegisterReleaseBinary 注册一个按定义安装的工具
+func RegisterReleaseBinary(def *ReleaseBinary) {
+	Register(def.Name, func(cfg *config.Config) Manager { return NewReleaseBinaryManager(def, cfg) })
+}
+
+func (rm *ReleaseBinaryManager) GetName() string {
+	return rm.def.Name
+}
+
+func (rm *ReleaseBinaryManager) GetMetadata() *Metadata {
+	homepage := rm.def.Homepage
+	if homepage == "" {
+		homepage = repoHomepage(rm.config, rm.def.Source, rm.def.Owner, rm.def.Repo)
+	}
+	return &Metadata{
+		Name:         rm.def.Name,
+		Description:  rm.def.Description,
+		Homepage:     homepage,
+		OS:           rm.def.OS,
+		Arch:         rm.def.Arch,
+		Dependencies: rm.def.Dependencies,
+		After:        rm.def.After,
+		DefaultTag:   rm.def.DefaultTag,
+	}
+}
+
+// userBinDir 是无法提权时Release工具的安装目录
+const userBinDir = "~/.local/bin"
+
+// configuredDir 返回定义中的安装目录
+func (rm *ReleaseBinaryManager) configuredDir() string {
+	dir := rm.def.InstallDir
+	if dir == "" {
+		dir = "/usr/local/bin"
+	}
+	return expandHome(dir, rm.config.HomeDir)
+}
+
+// needsPrivilege 返回写入path是否需要提权, 用户主目录以外的路径都需要
+func (rm *ReleaseBinaryManager) needsPrivilege(path string) bool {
+	return !strings.HasPrefix(path, rm.config.HomeDir+"/")
+}
+
+// installDir 返回本次安装的目录, 目录不在用户主目录中时需要提权; 无法提权时改为安装到 ~/.local/bin
+func (rm *ReleaseBinaryManager) installDir() (string, bool) {
+	dir := rm.configuredDir()
+	if !rm.needsPrivilege(dir) {
+		return dir, false
+	}
+	if !utils.HasPrivilege(rm.config.IsRoot) {
+		return expandHome(userBinDir, rm.config.HomeDir), false
+	}
+	return dir, true
+}
+
+// binPath 返回本次安装的可执行文件路径
+func (rm *ReleaseBinaryManager) binPath() string {
+	dir, _ := rm.installDir()
+	return filepath.Join(dir, rm.def.binName())
+}
+
+// installedPath 返回已安装的可执行文件路径。安装目录取决于安装时能否提权,
+// 因此优先使用安装记录中的路径, 没有记录时才按本次运行的安装目录推断
+func (rm *ReleaseBinaryManager) installedPath() string {
+	if rec, ok := getStore().Get(rm.def.Name); ok && len(rec.Files) > 0 {
+		return rec.Files[0].Path
+	}
+	return rm.binPath()
+}
+
+func (rm *ReleaseBinaryManager) githubInfo(flags *GlobalFlags) *utils.GithubRepoInfo {
+	return newRepoInfo(rm.config, rm.def.Source, rm.def.Owner, rm.def.Repo, flags)
+}
+
+func (rm *ReleaseBinaryManager) IsInstalled() bool {
+	return utils.FileExists(rm.installedPath()) || utils.IsCommandAvailable(rm.def.binName())
+}
+
+func (rm *ReleaseBinaryManager) Status(flags *GlobalFlags) *AppStatus {
+	status := newAppStatus(rm.def.Name, rm.IsInstalled())
+	if status.Installed {
+		versionCmd := rm.def.VersionCmd
+		if versionCmd == "" {
+			versionCmd = rm.installedPath() + " --version"
+		}
+		if version := commandVersion(versionCmd); version != "" {
+			status.Version = version
+		}
+	}
+	if latest, err := rm.githubInfo(flags).GetLatestReleaseTag(); err == nil {
+		status.Latest = latest
+	} else {
+		rm.config.Logger.Warn(err)
+	}
+	return status
+}
+
+func (rm *ReleaseBinaryManager) BundleResources(osType, arch string, flags *GlobalFlags) ([]utils.BundleResource, error) {
+	tagName, srcFileName, err := resolveRelease(rm.githubInfo(flags), flags, rm.def.DefaultTag, rm.def.assetRule(), osType, arch, rm.config.Logger)
+	if err != nil {
+		return nil, err
+	}
+	return []utils.BundleResource{{Owner: rm.def.Owner, Repo: rm.def.Repo, Tag: tagName, Asset: srcFileName}}, nil
+}
+
+func (rm *ReleaseBinaryManager) Installing(flags *GlobalFlags, tx *utils.Transaction) (*state.Record, error) {
+	githubInfo := rm.githubInfo(flags)
+	tagName, srcFileName, err := resolveRelease(githubInfo, flags, rm.def.DefaultTag, rm.def.assetRule(), rm.config.OS, rm.config.ARCH, rm.config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	workDir := filepath.Join(os.TempDir(), "envsetup-"+rm.def.Name)
+	if err := utils.RemoveFile(workDir, rm.config.Logger); err != nil {
+		return nil, err
+	}
+	if err := utils.Mkdir(workDir, rm.config.Logger); err != nil {
+		return nil, err
+	}
+	// 临时目录清理失败不影响安装
+	defer func() { _ = utils.RemoveFile(workDir, rm.config.Logger) }()
+
+	downloadFile := filepath.Join(workDir, srcFileName)
+	if format := rm.def.Format; format != "" && format != binaryFormat && !strings.HasSuffix(srcFileName, "."+format) {
+		// 解压时根据扩展名判断格式
+		downloadFile += "." + format
+	}
+	if err := githubInfo.DownloadReleaseLatestFile(downloadFile, srcFileName, tagName); err != nil {
+		return nil, err
+	}
+	if err := verifyDownload(githubInfo, downloadFile, srcFileName, tagName, rm.def.Checksums, flags, rm.config.Logger); err != nil {
+		return nil, err
+	}
+
+	srcPath := downloadFile
+	if rm.def.isArchive(srcFileName) {
+		extractDir := filepath.Join(workDir, "extract")
+		if err := utils.Unarchive(downloadFile, extractDir, rm.config.Logger); err != nil {
+			rm.config.Logger.Errorf("解压文件%s失败:%s", downloadFile, err)
+			return nil, err
+		}
+		if srcPath, err = rm.findBinary(extractDir, tagName); err != nil {
+			return nil, err
+		}
+	}
+
+	installDir, isSudo := rm.installDir()
+	if installDir != rm.configuredDir() {
+		rm.config.Logger.Warnf("无法获取root权限, %s改为安装到%s", rm.def.Name, installDir)
+		if !inPath(installDir) {
+			rm.config.Logger.Warnf("%s不在PATH中, 请将其加入PATH", installDir)
+		}
+	}
+	if !utils.DirectoryExists(installDir) {
+		tx.TrackCreated(installDir, isSudo)
+		cmdStr := utils.GenerateCmd(fmt.Sprintf("mkdir -p %s", installDir), isSudo, rm.config.IsRoot)
+		if err := utils.ExecCmd(cmdStr, rm.config.Logger); err != nil {
+			return nil, err
+		}
+	}
+	binPath := rm.binPath()
+	if err := tx.Backup(binPath, isSudo); err != nil {
+		return nil, err
+	}
+	cmdStr := utils.GenerateCmd(fmt.Sprintf("install -m 755 %s %s", srcPath, binPath), isSudo, rm.config.IsRoot)
+	if err := utils.ExecCmd(cmdStr, rm.config.Logger); err != nil {
+		return nil, err
+	}
+
+	rec := &state.Record{
+		Name:    rm.def.Name,
+		Version: tagName,
+		Source:  githubInfo.GetReleaseFileUrl(srcFileName, tagName),
+		Files:   newFileRecords(binPath),
+	}
+	if rm.postInstall != nil {
+		if err := rm.postInstall(flags, tx, rec); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// findBinary 在解压目录中找到要安装的可执行文件
+func (rm *ReleaseBinaryManager) findBinary(extractDir, tagName string) (string, error) {
+	if rm.def.Path != "" {
+		path := filepath.Join(extractDir, rm.def.expand(rm.def.Path, tagName))
+		if !utils.IsDryRun() && !utils.FileExists(path) {
+			return "", fmt.Errorf("压缩包中没有文件%s", rm.def.expand(rm.def.Path, tagName))
+		}
+		return path, nil
+	}
+	if utils.IsDryRun() {
+		return filepath.Join(extractDir, rm.def.binName()), nil
+	}
+
+	var found string
+	err := filepath.WalkDir(extractDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == rm.def.binName() {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", fmt.Errorf("压缩包中没有名为%s的文件", rm.def.binName())
+	}
+	return found, nil
+}
+
+func (rm *ReleaseBinaryManager) Install(flags *GlobalFlags) error {
+	if !flags.Force && rm.IsInstalled() {
+		rm.config.Logger.Warnf("%s已经安装。使用 -f 选项强制重新安装。", rm.def.Name)
+		return nil
+	}
+	return runInstalling(rm.config, rm.def.Name, "安装", func(tx *utils.Transaction) (*state.Record, error) {
+		return rm.Installing(flags, tx)
+	})
+}
+
+func (rm *ReleaseBinaryManager) Update(flags *GlobalFlags) error {
+	if !rm.IsInstalled() {
+		rm.config.Logger.Warnf("%s尚未安装。请使用 'install' 命令首先安装它。", rm.def.Name)
+		return nil
+	}
+	return runInstalling(rm.config, rm.def.Name, "更新", func(tx *utils.Transaction) (*state.Record, error) {
+		return rm.Installing(flags, tx)
+	})
+}
+
+func (rm *ReleaseBinaryManager) Delete(flags *GlobalFlags) error {
+	name := rm.def.Name
+	rm.config.Logger.Infof("开始删除%s...", name)
+	binPath := rm.installedPath()
+	cmdStr := utils.GenerateCmd(fmt.Sprintf("rm -f %s", binPath), rm.needsPrivilege(binPath), rm.config.IsRoot)
+	if err := utils.ExecCmd(cmdStr, rm.config.Logger); err != nil {
+		rm.config.Logger.Errorf("%s删除失败!", name)
+		return err
+	}
+	removeState(name)
+	rm.config.Logger.Infof("%s删除成功!", name)
+	return nil
+}
+
+// inPath 返回dir是否在PATH中
+func inPath(dir string) bool {
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(p) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandHome 将路径开头的 ~ 替换为用户主目录
+func expandHome(path, homeDir string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return homeDir + path[1:]
+	}
+	return path
+}
+
+// newRepoInfo 创建sourceName平台上的仓库, sourceName 为空时为GitHub(仍受配置文件中 repos 的影响)
+func newRepoInfo(cfg *config.Config, sourceName, owner, repo string, flags *GlobalFlags) *utils.GithubRepoInfo {
+	if sourceName != "" && cfg.Settings != nil {
+		// 加载应用定义时已经检查过代码平台
+		if ss, ok := cfg.Settings.GetSource(sourceName); ok {
+			if source, err := utils.NewSource(ss.Type, ss.URL, ss.Token); err == nil {
+				return utils.NewRepoInfo(source, owner, repo, flags.HttpProxy, flags.GithubProxy, cfg.Logger)
+			}
+		}
+	}
+	return utils.NewGithubRepoInfo(owner, repo, flags.HttpProxy, flags.GithubProxy, cfg.Logger)
+}
+
+// repoHomepage 返回仓库的网页地址
+func repoHomepage(cfg *config.Config, sourceName, owner, repo string) string {
+	if sourceName == "" {
+		return fmt.Sprintf("https://github.com/%s/%s", owner, repo)
+	}
+	return strings.TrimSuffix(newRepoInfo(cfg, sourceName, owner, repo, &GlobalFlags{}).GetOriginRepoUrl(), ".git")
+}
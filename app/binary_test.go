@@ -0,0 +1,214 @@
+package app
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// 除各平台的安装文件外, Release中常见的其他文件
+var releaseNoise = map[string][]string{
+	"chsrc":   {"chsrc-x64-windows.exe", "chsrc-riscv64-linux"},
+	"fzf":     {"fzf-{version}-linux_armv7.tar.gz", "fzf-{version}-windows_amd64.zip", "fzf_{version}_checksums.txt"},
+	"ripgrep": {"ripgrep_{version}-1_amd64.deb", "ripgrep-{version}-x86_64-pc-windows-msvc.zip", "ripgrep-{version}-x86_64-unknown-linux-musl.tar.gz.sha256"},
+	"bat":     {"bat-{tag}-x86_64-unknown-linux-musl.tar.gz", "bat_{version}_amd64.deb", "bat-{tag}-arm-unknown-linux-gnueabihf.tar.gz"},
+	"lazygit": {"lazygit_{version}_Linux_32-bit.tar.gz", "lazygit_{version}_Windows_x86_64.zip", "checksums.txt"},
+	"vmr":     {"vmr_windows-amd64.zip"},
+}
+
+func TestReleaseBinariesMatch(t *testing.T) {
+	for _, def := range append(releaseBinaries, vmrBinary) {
+		def := def
+		t.Run(def.Name, func(t *testing.T) {
+			tagName := def.DefaultTag
+			release := &utils.Release{TagName: tagName}
+			for _, tmpl := range def.Assets {
+				release.Assets = append(release.Assets, utils.ReleaseAsset{Name: def.expand(tmpl, tagName)})
+			}
+			for _, tmpl := range releaseNoise[def.Name] {
+				release.Assets = append(release.Assets, utils.ReleaseAsset{Name: def.expand(tmpl, tagName)})
+			}
+			rule := def.assetRule()
+			for _, osType := range def.OS {
+				for _, arch := range def.Arch {
+					want := rule.Fallback(tagName, osType, arch)
+					if want == "" {
+						t.Fatalf("缺少%s/%s的文件名模板", osType, arch)
+					}
+					asset, err := rule.Match(release, osType, arch)
+					if err != nil {
+						t.Fatalf("Match(%s/%s) error = %v", osType, arch, err)
+					}
+					if asset.Name != want {
+						t.Errorf("Match(%s/%s) = %s, want %s", osType, arch, asset.Name, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReleaseBinaryIsArchive(t *testing.T) {
+	tests := []struct {
+		format string
+		asset  string
+		want   bool
+	}{
+		{asset: "tool-linux-amd64.tar.gz", want: true},
+		{asset: "tool-linux-amd64.ZIP", want: true},
+		{asset: "tool-x64-linux", want: false},
+		{format: "binary", asset: "tool.tar.gz", want: false},
+		{format: "tar.gz", asset: "tool-linux-amd64", want: true},
+	}
+	for _, tt := range tests {
+		def := &ReleaseBinary{Format: tt.format}
+		if got := def.isArchive(tt.asset); got != tt.want {
+			t.Errorf("isArchive(%q, %q) = %v, want %v", tt.format, tt.asset, got, tt.want)
+		}
+	}
+}
+
+// newTarGz 生成只包含一个可执行文件的tar.gz
+func newTarGz(t *testing.T, name, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReleaseBinaryInstalling(t *testing.T) {
+	archive := newTarGz(t, "tool-1.2.0-linux-amd64/tool", "#!/bin/sh\necho tool 1.2.0\n")
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/o/tool/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"tag_name":"v1.2.0","assets":[{"name":"tool-1.2.0-linux-amd64.tar.gz"},{"name":"tool-1.2.0-darwin-amd64.tar.gz"}]}`)
+	})
+	mux.HandleFunc("/o/tool/releases/download/v1.2.0/tool-1.2.0-linux-amd64.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	utils.SetRepoSources(map[string]utils.RepoSource{
+		"o/tool": {Source: &utils.GithubSource{API: server.URL, Web: server.URL}, Owner: "o", Repo: "tool"},
+	})
+	defer utils.SetRepoSources(nil)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	home := t.TempDir()
+	cfg := &config.Config{Logger: logger, OS: "linux", ARCH: "amd64", HomeDir: home}
+
+	tests := []struct {
+		name string
+		def  *ReleaseBinary
+	}{
+		{name: "按名称查找可执行文件", def: &ReleaseBinary{Name: "tool", Owner: "o", Repo: "tool", InstallDir: "~/.local/bin"}},
+		{name: "指定压缩包中的路径", def: &ReleaseBinary{Name: "tool", Owner: "o", Repo: "tool", InstallDir: "~/bin", Path: "tool-{version}-linux-amd64/tool", Bin: "t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := NewReleaseBinaryManager(tt.def, cfg)
+			tx := utils.NewTransaction("测试安装", false, logger)
+			rec, err := rm.Installing(&GlobalFlags{SkipVerify: true}, tx)
+			if err != nil {
+				t.Fatalf("Installing() error = %v", err)
+			}
+			tx.Commit()
+
+			wantPath := filepath.Join(home, strings.TrimPrefix(tt.def.InstallDir, "~/"), tt.def.binName())
+			if rec.Version != "v1.2.0" || len(rec.Files) != 1 || rec.Files[0].Path != wantPath {
+				t.Errorf("Installing() = %+v", rec)
+			}
+			info, err := os.Stat(wantPath)
+			if err != nil {
+				t.Fatalf("可执行文件没有安装: %v", err)
+			}
+			if info.Mode().Perm() != 0o755 {
+				t.Errorf("可执行文件权限 = %v, want 0755", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+// TestMain 让测试使用临时目录中的安装状态库, 不读取全局配置
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "envsetup-state")
+	if err != nil {
+		panic(err)
+	}
+	storeOnce.Do(func() {})
+	store = state.NewStore(filepath.Join(dir, "state.json"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useTestStore 让单个测试使用独立的安装状态库
+func useTestStore(t *testing.T) *state.Store {
+	t.Helper()
+	saved := store
+	store = state.NewStore(filepath.Join(t.TempDir(), "state.json"))
+	t.Cleanup(func() { store = saved })
+	return store
+}
+
+func TestReleaseBinaryUsesRecordedPath(t *testing.T) {
+	s := useTestStore(t)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	home := t.TempDir()
+	cfg := &config.Config{Logger: logger, OS: "linux", ARCH: "amd64", HomeDir: home}
+	rm := NewReleaseBinaryManager(&ReleaseBinary{Name: "tool", Owner: "o", Repo: "tool", InstallDir: "~/.local/bin"}, cfg)
+
+	// 之前安装在用户主目录以外, 例如当时可以提权安装到 /usr/local/bin
+	recorded := filepath.Join(t.TempDir(), "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(recorded), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(recorded, []byte("#!/bin/sh\necho tool 1.0.0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(&state.Record{Name: "tool", Files: []state.FileRecord{{Path: recorded}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !rm.IsInstalled() {
+		t.Error("IsInstalled() = false, want 按安装记录中的路径判断")
+	}
+	utils.SetDryRun(true)
+	defer utils.SetDryRun(false)
+	steps := len(utils.GetPlan())
+	if err := rm.Delete(&GlobalFlags{}); err != nil {
+		t.Fatal(err)
+	}
+	plan := utils.GetPlan()[steps:]
+	if len(plan) != 1 || !strings.HasSuffix(plan[0].Target, "rm -f "+recorded) {
+		t.Errorf("Delete() = %+v, want 删除%s", plan, recorded)
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// Bundler 由需要从GitHub下载文件或克隆仓库的应用实现, 用于制作离线安装包
+type Bundler interface {
+	// BundleResources 返回在osType/arch平台安装时需要的资源
+	BundleResources(osType, arch string, flags *GlobalFlags) ([]utils.BundleResource, error)
+}
+
+// CreateBundle 为names中的应用及其依赖制作osType/arch平台的离线安装包
+func CreateBundle(managers []Manager, names []string, osType, arch, out string, flags *GlobalFlags) error {
+	logger := config.GetConfig().Logger
+
+	ordered, err := ResolveOrder(managers, names)
+	if err != nil {
+		return err
+	}
+
+	manifest := &utils.BundleManifest{OS: osType, Arch: arch}
+	var resources []utils.BundleResource
+	seen := make(map[utils.BundleResource]bool)
+	for _, mgr := range ordered {
+		if !mgr.GetMetadata().Supports(osType, arch) {
+			return fmt.Errorf("应用%s不支持%s/%s平台", mgr.GetName(), osType, arch)
+		}
+		manifest.Apps = append(manifest.Apps, mgr.GetName())
+		bundler, ok := mgr.(Bundler)
+		if !ok {
+			logger.Infof("%s不需要从GitHub获取资源", mgr.GetName())
+			continue
+		}
+		needed, err := bundler.BundleResources(osType, arch, flags)
+		if err != nil {
+			return err
+		}
+		for _, res := range needed {
+			if !seen[res] {
+				seen[res] = true
+				resources = append(resources, res)
+			}
+		}
+	}
+	return utils.CreateBundle(resources, manifest, out, flags.HttpProxy, flags.GithubProxy, logger)
+}
+
+// UseBundle 打开离线安装包并在之后的安装中使用, 返回的函数用于清理解压的文件
+func UseBundle(path string) (func(), error) {
+	cfg := config.GetConfig()
+	bundle, err := utils.OpenBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := bundle.Manifest()
+	if manifest.OS != cfg.OS || manifest.Arch != cfg.ARCH {
+		bundle.Close()
+		return nil, fmt.Errorf("离线安装包%s适用于%s/%s, 当前系统为%s/%s", path, manifest.OS, manifest.Arch, cfg.OS, cfg.ARCH)
+	}
+	cfg.Logger.Infof("使用离线安装包%s, 包含应用: %v", path, manifest.Apps)
+	utils.SetBundle(bundle)
+	return func() {
+		utils.SetBundle(nil)
+		if err := bundle.Close(); err != nil {
+			cfg.Logger.Warnf("清理离线安装包临时文件失败:%s", err)
+		}
+	}, nil
+}
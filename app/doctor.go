@@ -0,0 +1,218 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// 环境检查结果
+const (
+	CheckPass = "pass"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// CheckResult 描述一项环境检查的结果
+type CheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// RunDoctor 检查所有应用依赖的系统环境
+func RunDoctor(flags *GlobalFlags) []*CheckResult {
+	cfg := config.GetConfig()
+	results := []*CheckResult{
+		checkPackageManager(cfg),
+		checkSudo(cfg),
+		checkCommand("git", CheckWarn, "envsetup内置Git客户端, 但部分应用的脚本依赖系统git", "使用包管理器安装git"),
+		checkPython(),
+		checkCommand("zsh", CheckWarn, "安装ohmyzsh时会自动安装zsh", ""),
+		checkCommand("vim", CheckWarn, "安装vimrc时会自动安装vim", ""),
+		checkWritable("/usr/local/bin", cfg),
+		checkWritable(cfg.DataDir, cfg),
+	}
+	results = append(results, checkNetwork(flags)...)
+	results = append(results, checkRateLimit(cfg, flags))
+	results = append(results, checkShellRc(cfg)...)
+	return results
+}
+
+func checkPackageManager(cfg *config.Config) *CheckResult {
+	result := &CheckResult{Name: "包管理器"}
+	installer, err := utils.GetInstaller(cfg.IsRoot, cfg.Logger)
+	if err != nil {
+		result.Status = CheckFail
+		result.Message = err.Error()
+		result.Suggestion = "请安装apt-get、dnf、yum、pacman、zypper、apk、brew、port或nix-env中的一种"
+		return result
+	}
+	result.Status = CheckPass
+	result.Message = fmt.Sprintf("使用%s", installer.GetPackageManager())
+	return result
+}
+
+func checkSudo(cfg *config.Config) *CheckResult {
+	result := &CheckResult{Name: "提权"}
+	tool := utils.PrivilegeTool()
+	switch {
+	case cfg.IsRoot:
+		result.Status = CheckPass
+		result.Message = "当前为root用户, 无需sudo"
+	case tool == "":
+		result.Status = CheckFail
+		result.Message = "未找到sudo或doas, 无法安装系统软件包和写入系统目录"
+		result.Suggestion = "使用root用户运行, 或安装并配置sudo/doas; Release工具会改为安装到~/.local/bin"
+	default:
+		if _, err := utils.CmdOutput(tool + " -n true"); err != nil {
+			result.Status = CheckWarn
+			result.Message = fmt.Sprintf("%s需要输入密码", tool)
+			result.Suggestion = fmt.Sprintf("安装过程中请留意%s密码提示; 使用 --non-interactive 时请配置免密或 SUDO_ASKPASS", tool)
+		} else {
+			result.Status = CheckPass
+			result.Message = fmt.Sprintf("%s可用且无需密码", tool)
+		}
+	}
+	return result
+}
+
+func checkCommand(command, missingStatus, missingMessage, suggestion string) *CheckResult {
+	result := &CheckResult{Name: command}
+	if utils.IsCommandAvailable(command) {
+		result.Status = CheckPass
+		result.Message = fmt.Sprintf("已安装%s", command)
+		return result
+	}
+	result.Status = missingStatus
+	result.Message = fmt.Sprintf("未找到%s: %s", command, missingMessage)
+	result.Suggestion = suggestion
+	return result
+}
+
+func checkPython() *CheckResult {
+	result := &CheckResult{Name: "python"}
+	for _, command := range []string{"python3", "python"} {
+		if utils.IsCommandAvailable(command) {
+			result.Status = CheckPass
+			result.Message = fmt.Sprintf("已安装%s", command)
+			return result
+		}
+	}
+	result.Status = CheckWarn
+	result.Message = "未找到python解释器, 无法更新vimrc插件"
+	result.Suggestion = "使用包管理器安装python3"
+	return result
+}
+
+// checkWritable 检查目录是否可写, 不存在时检查其最近的已存在的上级目录
+func checkWritable(dir string, cfg *config.Config) *CheckResult {
+	result := &CheckResult{Name: fmt.Sprintf("写入%s", dir)}
+	path := dir
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			break
+		}
+		path = parent
+	}
+
+	// 2 即 W_OK, 按当前用户的真实权限检查是否可写
+	if syscall.Access(path, 2) == nil {
+		result.Status = CheckPass
+		result.Message = fmt.Sprintf("%s可写", path)
+		return result
+	}
+	if cfg.IsRoot || utils.PrivilegeTool() != "" {
+		result.Status = CheckWarn
+		result.Message = fmt.Sprintf("%s不可写, 需要%s权限", path, utils.PrivilegeTool())
+		return result
+	}
+	result.Status = CheckFail
+	result.Message = fmt.Sprintf("%s不可写且无法提权", path)
+	result.Suggestion = fmt.Sprintf("修改%s的权限, 或使用root用户运行", path)
+	return result
+}
+
+func checkNetwork(flags *GlobalFlags) []*CheckResult {
+	type target struct {
+		name string
+		url  string
+	}
+	targets := []target{
+		{"GitHub", "https://github.com"},
+		{"GitHub API", "https://api.github.com"},
+	}
+	// --github-proxy=auto 时检查测速选出的镜像
+	githubProxy := utils.ResolveGithubProxy(flags.GithubProxy, flags.HttpProxy, config.GetConfig().Logger)
+	if githubProxy != "" {
+		targets = append(targets, target{"GitHub代理", githubProxy})
+	}
+
+	var results []*CheckResult
+	for _, target := range targets {
+		result := &CheckResult{Name: fmt.Sprintf("访问%s", target.name)}
+		elapsed, err := utils.CheckReachable(target.url, flags.HttpProxy, 10*time.Second)
+		if err != nil {
+			result.Status = CheckFail
+			result.Message = fmt.Sprintf("无法访问%s: %s", target.url, err)
+			result.Suggestion = "检查网络, 或使用 --https-proxy / --github-proxy 指定代理"
+		} else {
+			result.Status = CheckPass
+			result.Message = fmt.Sprintf("%s 响应耗时%s", target.url, elapsed.Round(time.Millisecond))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func checkRateLimit(cfg *config.Config, flags *GlobalFlags) *CheckResult {
+	result := &CheckResult{Name: "GitHub API限额"}
+	limit, err := utils.GetRateLimit(flags.HttpProxy, cfg.Logger)
+	if err != nil {
+		result.Status = CheckWarn
+		result.Message = fmt.Sprintf("查询GitHub API限额失败: %s", err)
+		return result
+	}
+	result.Message = fmt.Sprintf("剩余%d/%d次, %s重置", limit.Remaining, limit.Limit, limit.Reset.Local().Format("15:04:05"))
+	switch {
+	case limit.Remaining == 0:
+		result.Status = CheckFail
+		result.Suggestion = "等待限额重置, 或设置环境变量GITHUB_TOKEN / 使用--github-token"
+	case limit.Remaining < 10:
+		result.Status = CheckWarn
+		result.Suggestion = "设置环境变量GITHUB_TOKEN或使用--github-token提高上限"
+	default:
+		result.Status = CheckPass
+	}
+	return result
+}
+
+func checkShellRc(cfg *config.Config) []*CheckResult {
+	var results []*CheckResult
+	for _, name := range []string{".bashrc", ".zshrc"} {
+		path := filepath.Join(cfg.HomeDir, name)
+		result := &CheckResult{Name: name}
+		if utils.FileExists(path) {
+			result.Status = CheckPass
+			result.Message = fmt.Sprintf("%s存在", path)
+		} else {
+			result.Status = CheckWarn
+			result.Message = fmt.Sprintf("%s不存在, vmr不会向其写入环境配置", path)
+			if name == ".zshrc" {
+				result.Suggestion = "先安装ohmyzsh再安装vmr"
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolveOrder 根据应用之间的依赖和顺序约束对names排序。
+// Dependencies 中缺少的应用会被自动加入; After 只在两个应用都被选中时约束顺序。
+// 存在未知应用或循环依赖时返回错误, 不会执行任何操作
+func ResolveOrder(managers []Manager, names []string) ([]Manager, error) {
+	byName := make(map[string]Manager, len(managers))
+	for _, mgr := range managers {
+		byName[mgr.GetName()] = mgr
+	}
+
+	// 收集选中的应用及其全部依赖
+	selected := make(map[string]bool)
+	var collect func(name, requiredBy string) error
+	collect = func(name, requiredBy string) error {
+		mgr, ok := byName[name]
+		if !ok {
+			if requiredBy != "" {
+				return fmt.Errorf("应用%s依赖的%s不存在或不支持当前平台", requiredBy, name)
+			}
+			return fmt.Errorf("应用%s不存在或不支持当前平台", name)
+		}
+		if selected[name] {
+			return nil
+		}
+		selected[name] = true
+		for _, dep := range mgr.GetMetadata().Dependencies {
+			if err := collect(dep, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := collect(name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// 深度优先遍历, 先输出前置应用; 遇到正在访问的节点说明存在环
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int)
+	var order []Manager
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for i, item := range path {
+				if item == name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("应用之间存在循环依赖: %s", strings.Join(cycle, " -> "))
+		}
+
+		marks[name] = visiting
+		path = append(path, name)
+		meta := byName[name].GetMetadata()
+		for _, prev := range append(append([]string{}, meta.Dependencies...), meta.After...) {
+			if !selected[prev] {
+				continue
+			}
+			if err := visit(prev); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[name] = visited
+		order = append(order, byName[name])
+		return nil
+	}
+
+	// 按用户给出的顺序遍历, 没有约束的应用保持原有顺序
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// Reverse 返回倒序的应用列表, 删除时先删除依赖方
+func Reverse(managers []Manager) []Manager {
+	reversed := make([]Manager, len(managers))
+	for i, mgr := range managers {
+		reversed[len(managers)-1-i] = mgr
+	}
+	return reversed
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type fakeManager struct {
+	meta *Metadata
+}
+
+func (f *fakeManager) GetName() string                      { return f.meta.Name }
+func (f *fakeManager) GetMetadata() *Metadata               { return f.meta }
+func (f *fakeManager) IsInstalled() bool                    { return false }
+func (f *fakeManager) Install(flags *GlobalFlags) error     { return nil }
+func (f *fakeManager) Update(flags *GlobalFlags) error      { return nil }
+func (f *fakeManager) Delete(flags *GlobalFlags) error      { return nil }
+func (f *fakeManager) Status(flags *GlobalFlags) *AppStatus { return newAppStatus(f.meta.Name, false) }
+
+func newFakeManagers(metas ...*Metadata) []Manager {
+	managers := make([]Manager, 0, len(metas))
+	for _, meta := range metas {
+		managers = append(managers, &fakeManager{meta: meta})
+	}
+	return managers
+}
+
+func managerNames(managers []Manager) []string {
+	names := make([]string, 0, len(managers))
+	for _, mgr := range managers {
+		names = append(names, mgr.GetName())
+	}
+	return names
+}
+
+func TestResolveOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		managers []*Metadata
+		names    []string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name:     "保持用户给出的顺序",
+			managers: []*Metadata{{Name: "a"}, {Name: "b"}, {Name: "c"}},
+			names:    []string{"c", "a", "b"},
+			want:     []string{"c", "a", "b"},
+		},
+		{
+			name:     "自动加入依赖并排在前面",
+			managers: []*Metadata{{Name: "zsh"}, {Name: "ohmyzsh", Dependencies: []string{"zsh"}}},
+			names:    []string{"ohmyzsh"},
+			want:     []string{"zsh", "ohmyzsh"},
+		},
+		{
+			name:     "传递依赖",
+			managers: []*Metadata{{Name: "a", Dependencies: []string{"b"}}, {Name: "b", Dependencies: []string{"c"}}, {Name: "c"}},
+			names:    []string{"a"},
+			want:     []string{"c", "b", "a"},
+		},
+		{
+			name:     "After只约束同时选中的应用",
+			managers: []*Metadata{{Name: "a", After: []string{"b"}}, {Name: "b"}},
+			names:    []string{"a"},
+			want:     []string{"a"},
+		},
+		{
+			name:     "After调整同时选中的应用顺序",
+			managers: []*Metadata{{Name: "a", After: []string{"b"}}, {Name: "b"}},
+			names:    []string{"a", "b"},
+			want:     []string{"b", "a"},
+		},
+		{
+			name:     "重复的应用只出现一次",
+			managers: []*Metadata{{Name: "a", Dependencies: []string{"b"}}, {Name: "b"}},
+			names:    []string{"b", "a", "b"},
+			want:     []string{"b", "a"},
+		},
+		{
+			name:     "未知应用",
+			managers: []*Metadata{{Name: "a"}},
+			names:    []string{"x"},
+			wantErr:  "应用x不存在或不支持当前平台",
+		},
+		{
+			name:     "依赖的应用不存在",
+			managers: []*Metadata{{Name: "a", Dependencies: []string{"x"}}},
+			names:    []string{"a"},
+			wantErr:  "应用a依赖的x不存在或不支持当前平台",
+		},
+		{
+			name:     "依赖形成环",
+			managers: []*Metadata{{Name: "a", Dependencies: []string{"b"}}, {Name: "b", Dependencies: []string{"c"}}, {Name: "c", Dependencies: []string{"a"}}},
+			names:    []string{"a"},
+			wantErr:  "应用之间存在循环依赖: a -> b -> c -> a",
+		},
+		{
+			name:     "After形成环",
+			managers: []*Metadata{{Name: "a", After: []string{"b"}}, {Name: "b", After: []string{"a"}}},
+			names:    []string{"a", "b"},
+			wantErr:  "应用之间存在循环依赖: a -> b -> a",
+		},
+		{
+			name:     "自依赖",
+			managers: []*Metadata{{Name: "a", Dependencies: []string{"a"}}},
+			names:    []string{"a"},
+			wantErr:  "应用之间存在循环依赖: a -> a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := ResolveOrder(newFakeManagers(tt.managers...), tt.names)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveOrder() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveOrder() error = %v", err)
+			}
+			if got := managerNames(order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveOrder() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverse(t *testing.T) {
+	managers := newFakeManagers(&Metadata{Name: "a"}, &Metadata{Name: "b"}, &Metadata{Name: "c"})
+	if got, want := managerNames(Reverse(managers)), []string{"c", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Reverse() = %v, want %v", got, want)
+	}
+}
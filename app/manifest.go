@@ -0,0 +1,417 @@
+package app
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// LoadManifests 注册dir中定义的应用, 无效的定义和与已注册应用重名的定义只给出警告
+func LoadManifests(dir string, cfg *config.Config) {
+	manifests, errs := config.LoadManifests(dir, cfg.Settings)
+	for _, err := range errs {
+		cfg.Logger.Warnf("%s, 已忽略", err)
+	}
+	for _, manifest := range manifests {
+		if isRegistered(manifest.Name) {
+			cfg.Logger.Warnf("应用定义%s中的应用%s与已有应用重名, 已忽略", manifest.File, manifest.Name)
+			continue
+		}
+		Register(manifest.Name, manifestFactory(manifest))
+	}
+}
+
+// manifestFactory 根据应用定义的类型创建对应的管理器
+func manifestFactory(m *config.Manifest) Factory {
+	switch m.Type {
+	case config.ManifestRelease:
+		def := &ReleaseBinary{
+			Name:         m.Name,
+			Description:  m.Description,
+			Homepage:     m.Homepage,
+			Source:       m.Source,
+			Owner:        m.Owner,
+			Repo:         m.Repo,
+			DefaultTag:   m.Tag,
+			OS:           m.OS,
+			Arch:         m.Arch,
+			Dependencies: m.Dependencies,
+			After:        m.After,
+			OSNames:      m.OSNames,
+			ArchNames:    m.ArchNames,
+			Suffixes:     m.Suffixes,
+			Exclude:      m.Exclude,
+			Assets:       m.Assets,
+			Format:       m.Format,
+			Path:         m.Path,
+			Bin:          m.Bin,
+			InstallDir:   m.InstallDir,
+			VersionCmd:   m.VersionCmd,
+			Checksums:    m.Checksums,
+		}
+		return func(cfg *config.Config) Manager { return NewReleaseBinaryManager(def, cfg) }
+	case config.ManifestRepo:
+		return func(cfg *config.Config) Manager { return &GitRepoManager{manifest: m, config: cfg} }
+	case config.ManifestPackage:
+		return func(cfg *config.Config) Manager { return &PackagesManager{manifest: m, config: cfg} }
+	default:
+		return func(cfg *config.Config) Manager { return &ScriptManager{manifest: m, config: cfg} }
+	}
+}
+
+func manifestMetadata(m *config.Manifest, homepage string) *Metadata {
+	if m.Homepage != "" {
+		homepage = m.Homepage
+	}
+	return &Metadata{
+		Name:         m.Name,
+		Description:  m.Description,
+		Homepage:     homepage,
+		OS:           m.OS,
+		Arch:         m.Arch,
+		Dependencies: m.Dependencies,
+		After:        m.After,
+	}
+}
+
+// runCheck 执行检查命令, 退出码为0时返回true
+func runCheck(cmdStr string) bool {
+	return exec.Command("bash", "-c", cmdStr).Run() == nil
+}
+
+// GitRepoManager 将Git仓库克隆到指定目录, 更新时拉取最新提交
+type GitRepoManager struct {
+	manifest *config.Manifest
+	config   *config.Config
+}
+
+func (gm *GitRepoManager) dir() string {
+	return expandHome(gm.manifest.Dir, gm.config.HomeDir)
+}
+
+func (gm *GitRepoManager) githubInfo(flags *GlobalFlags) *utils.GithubRepoInfo {
+	return newRepoInfo(gm.config, gm.manifest.Source, gm.manifest.Owner, gm.manifest.Repo, flags)
+}
+
+func (gm *GitRepoManager) GetName() string {
+	return gm.manifest.Name
+}
+
+func (gm *GitRepoManager) GetMetadata() *Metadata {
+	return manifestMetadata(gm.manifest, repoHomepage(gm.config, gm.manifest.Source, gm.manifest.Owner, gm.manifest.Repo))
+}
+
+func (gm *GitRepoManager) IsInstalled() bool {
+	return utils.DirectoryExists(filepath.Join(gm.dir(), ".git"))
+}
+
+func (gm *GitRepoManager) Status(flags *GlobalFlags) *AppStatus {
+	status := newAppStatus(gm.manifest.Name, gm.IsInstalled())
+	if head, err := utils.GetRepoHead(gm.dir()); err == nil {
+		status.Version = shortHash(head)
+	}
+	if head, err := gm.githubInfo(flags).GetRemoteHead(); err == nil {
+		status.Latest = shortHash(head)
+	} else {
+		gm.config.Logger.Warnf("获取%s远程版本失败:%s", gm.manifest.Repo, err)
+	}
+	return status
+}
+
+func (gm *GitRepoManager) record(githubInfo *utils.GithubRepoInfo) *state.Record {
+	rec := &state.Record{Name: gm.manifest.Name, Source: githubInfo.GetOriginRepoUrl()}
+	if head, err := utils.GetRepoHead(gm.dir()); err == nil {
+		rec.Version = shortHash(head)
+	}
+	return rec
+}
+
+func (gm *GitRepoManager) Install(flags *GlobalFlags) error {
+	if !flags.Force && gm.IsInstalled() {
+		gm.config.Logger.Warnf("%s已经安装。使用 -f 选项强制重新安装。", gm.manifest.Name)
+		return nil
+	}
+	return runInstalling(gm.config, gm.manifest.Name, "安装", func(tx *utils.Transaction) (*state.Record, error) {
+		githubInfo := gm.githubInfo(flags)
+		tx.TrackCreated(gm.dir(), false)
+		if err := githubInfo.CloneRepo(gm.dir()); err != nil {
+			return nil, err
+		}
+		return gm.record(githubInfo), nil
+	})
+}
+
+func (gm *GitRepoManager) Update(flags *GlobalFlags) error {
+	if !gm.IsInstalled() {
+		gm.config.Logger.Warnf("%s尚未安装。请使用 'install' 命令首先安装它。", gm.manifest.Name)
+		return nil
+	}
+	return runInstalling(gm.config, gm.manifest.Name, "更新", func(tx *utils.Transaction) (*state.Record, error) {
+		githubInfo := gm.githubInfo(flags)
+		if err := tx.BackupRepo(gm.dir()); err != nil {
+			return nil, err
+		}
+		if err := githubInfo.PullRepo(gm.dir()); err != nil {
+			return nil, err
+		}
+		return gm.record(githubInfo), nil
+	})
+}
+
+func (gm *GitRepoManager) Delete(flags *GlobalFlags) error {
+	gm.config.Logger.Infof("开始删除%s...", gm.manifest.Name)
+	if err := utils.RemoveFile(gm.dir(), gm.config.Logger); err != nil {
+		gm.config.Logger.Errorf("%s删除失败!", gm.manifest.Name)
+		return err
+	}
+	removeState(gm.manifest.Name)
+	gm.config.Logger.Infof("%s删除成功!", gm.manifest.Name)
+	return nil
+}
+
+// PackagesManager 使用系统的包管理器安装一组软件包
+type PackagesManager struct {
+	manifest *config.Manifest
+	config   *config.Config
+}
+
+func (pm *PackagesManager) GetName() string {
+	return pm.manifest.Name
+}
+
+func (pm *PackagesManager) GetMetadata() *Metadata {
+	return manifestMetadata(pm.manifest, "")
+}
+
+func (pm *PackagesManager) SystemPackages() []utils.SystemPackage {
+	var packages []utils.SystemPackage
+	for _, pkg := range pm.manifest.Packages {
+		packages = append(packages, utils.SystemPackage{Name: pkg})
+	}
+	return packages
+}
+
+// IsInstalled 执行 check 命令判断是否已安装, 未指定时查询包管理器, 要求每个软件包都已安装
+func (pm *PackagesManager) IsInstalled() bool {
+	if pm.manifest.Check != "" {
+		return runCheck(pm.manifest.Check)
+	}
+	installer, err := utils.GetInstaller(pm.config.IsRoot, pm.config.Logger)
+	for _, pkg := range pm.manifest.Packages {
+		if err != nil {
+			// 没有包管理器时只能检查同名的命令
+			if !utils.IsCommandAvailable(pkg) {
+				return false
+			}
+		} else if !installer.IsPackageInstalled(pkg) {
+			return false
+		}
+	}
+	return true
+}
+
+// Status 优先使用 version_cmd 查询版本, 未指定时使用第一个软件包已安装和可安装的版本
+func (pm *PackagesManager) Status(flags *GlobalFlags) *AppStatus {
+	status := newAppStatus(pm.manifest.Name, pm.IsInstalled())
+	if !status.Installed {
+		return status
+	}
+	if pm.manifest.VersionCmd != "" {
+		if version := commandVersion(pm.manifest.VersionCmd); version != "" {
+			status.Version = version
+		}
+		return status
+	}
+	installer, err := utils.GetInstaller(pm.config.IsRoot, pm.config.Logger)
+	if err != nil {
+		return status
+	}
+	pkg := pm.manifest.Packages[0]
+	if version, err := installer.InstalledVersion(pkg); err == nil && version != "" {
+		status.Version = version
+	}
+	if candidate, err := installer.CandidateVersion(pkg); err == nil {
+		status.Latest = candidate
+	} else {
+		pm.config.Logger.Warnf("获取%s可安装的版本失败:%s", pkg, err)
+	}
+	return status
+}
+
+// outdatedPackages 返回已安装版本与软件源中版本不同的软件包, 无法查询版本的软件包也会返回
+func (pm *PackagesManager) outdatedPackages(installer utils.Installer) []string {
+	var outdated []string
+	for _, pkg := range pm.manifest.Packages {
+		installed, err := installer.InstalledVersion(pkg)
+		if err != nil || installed == "" {
+			outdated = append(outdated, pkg)
+			continue
+		}
+		candidate, err := installer.CandidateVersion(pkg)
+		if err != nil || (candidate != "" && candidate != installed) {
+			outdated = append(outdated, pkg)
+		}
+	}
+	return outdated
+}
+
+func (pm *PackagesManager) installer() (utils.Installer, error) {
+	installer, err := utils.GetInstaller(pm.config.IsRoot, pm.config.Logger)
+	if err != nil {
+		pm.config.Logger.Errorf(err.Error())
+		return nil, err
+	}
+	return installer, nil
+}
+
+func (pm *PackagesManager) Install(flags *GlobalFlags) error {
+	if !flags.Force && pm.IsInstalled() {
+		pm.config.Logger.Warnf("%s已经安装。使用 -f 选项强制重新安装。", pm.manifest.Name)
+		return nil
+	}
+	installer, err := pm.installer()
+	if err != nil {
+		return err
+	}
+	return runInstalling(pm.config, pm.manifest.Name, "安装", func(tx *utils.Transaction) (*state.Record, error) {
+		if err := installer.Install(pm.manifest.Packages); err != nil {
+			return nil, err
+		}
+		return &state.Record{Name: pm.manifest.Name, Source: installer.GetPackageManager()}, nil
+	})
+}
+
+// Update 重新安装有新版本的软件包, 包管理器会将其升级到最新版本。使用 -f 时重新安装所有软件包
+func (pm *PackagesManager) Update(flags *GlobalFlags) error {
+	if !pm.IsInstalled() {
+		pm.config.Logger.Warnf("%s尚未安装。请使用 'install' 命令首先安装它。", pm.manifest.Name)
+		return nil
+	}
+	installer, err := pm.installer()
+	if err != nil {
+		return err
+	}
+	packages := pm.manifest.Packages
+	if !flags.Force {
+		packages = pm.outdatedPackages(installer)
+	}
+	if len(packages) == 0 {
+		pm.config.Logger.Infof("%s的软件包已是最新版本", pm.manifest.Name)
+		return nil
+	}
+	return runInstalling(pm.config, pm.manifest.Name, "更新", func(tx *utils.Transaction) (*state.Record, error) {
+		if err := installer.Install(packages); err != nil {
+			return nil, err
+		}
+		return &state.Record{Name: pm.manifest.Name, Source: installer.GetPackageManager()}, nil
+	})
+}
+
+func (pm *PackagesManager) Delete(flags *GlobalFlags) error {
+	installer, err := pm.installer()
+	if err != nil {
+		return err
+	}
+	pm.config.Logger.Infof("开始删除%s...", pm.manifest.Name)
+	if err := installer.Unintstall(pm.manifest.Packages); err != nil {
+		pm.config.Logger.Errorf("%s删除失败!", pm.manifest.Name)
+		return err
+	}
+	removeState(pm.manifest.Name)
+	pm.config.Logger.Infof("%s删除成功!", pm.manifest.Name)
+	return nil
+}
+
+// ScriptManager 通过执行应用定义中的shell命令安装、更新和删除应用
+type ScriptManager struct {
+	manifest *config.Manifest
+	config   *config.Config
+}
+
+func (sm *ScriptManager) GetName() string {
+	return sm.manifest.Name
+}
+
+func (sm *ScriptManager) GetMetadata() *Metadata {
+	return manifestMetadata(sm.manifest, "")
+}
+
+func (sm *ScriptManager) IsInstalled() bool {
+	return runCheck(sm.manifest.Check)
+}
+
+func (sm *ScriptManager) Status(flags *GlobalFlags) *AppStatus {
+	status := newAppStatus(sm.manifest.Name, sm.IsInstalled())
+	if status.Installed && sm.manifest.VersionCmd != "" {
+		if version := commandVersion(sm.manifest.VersionCmd); version != "" {
+			status.Version = version
+		}
+	}
+	return status
+}
+
+// run 执行命令, 通过环境变量向命令传递代理设置
+func (sm *ScriptManager) run(script string, flags *GlobalFlags) (*state.Record, error) {
+	var env []string
+	if flags.HttpProxy != "" {
+		env = append(env, fmt.Sprintf("https_proxy=%s", flags.HttpProxy))
+	}
+	if flags.Tag != "" {
+		env = append(env, fmt.Sprintf("ENVSETUP_TAG=%s", flags.Tag))
+	}
+	cmdStr := strings.TrimSpace(script)
+	if len(env) > 0 {
+		cmdStr = fmt.Sprintf("export %s\n%s", strings.Join(env, " "), cmdStr)
+	}
+	if err := utils.ExecCmd(cmdStr, sm.config.Logger); err != nil {
+		return nil, err
+	}
+	rec := &state.Record{Name: sm.manifest.Name}
+	if sm.manifest.VersionCmd != "" && !utils.IsDryRun() {
+		rec.Version = commandVersion(sm.manifest.VersionCmd)
+	}
+	return rec, nil
+}
+
+func (sm *ScriptManager) Install(flags *GlobalFlags) error {
+	if !flags.Force && sm.IsInstalled() {
+		sm.config.Logger.Warnf("%s已经安装。使用 -f 选项强制重新安装。", sm.manifest.Name)
+		return nil
+	}
+	return runInstalling(sm.config, sm.manifest.Name, "安装", func(tx *utils.Transaction) (*state.Record, error) {
+		return sm.run(sm.manifest.Install, flags)
+	})
+}
+
+func (sm *ScriptManager) Update(flags *GlobalFlags) error {
+	if !sm.IsInstalled() {
+		sm.config.Logger.Warnf("%s尚未安装。请使用 'install' 命令首先安装它。", sm.manifest.Name)
+		return nil
+	}
+	script := sm.manifest.Update
+	if script == "" {
+		script = sm.manifest.Install
+	}
+	return runInstalling(sm.config, sm.manifest.Name, "更新", func(tx *utils.Transaction) (*state.Record, error) {
+		return sm.run(script, flags)
+	})
+}
+
+func (sm *ScriptManager) Delete(flags *GlobalFlags) error {
+	if sm.manifest.Uninstall == "" {
+		return fmt.Errorf("应用定义%s没有指定uninstall, 无法删除%s", sm.manifest.File, sm.manifest.Name)
+	}
+	sm.config.Logger.Infof("开始删除%s...", sm.manifest.Name)
+	if _, err := sm.run(sm.manifest.Uninstall, flags); err != nil {
+		sm.config.Logger.Errorf("%s删除失败!", sm.manifest.Name)
+		return err
+	}
+	removeState(sm.manifest.Name)
+	sm.config.Logger.Infof("%s删除成功!", sm.manifest.Name)
+	return nil
+}
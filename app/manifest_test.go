@@ -0,0 +1,100 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/config"
+)
+
+func TestLoadManifestsRegister(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"tool.yaml":    "name: test-tool\ntype: release\ndescription: 内部工具\nowner: team\nrepo: tool\ntag: v1.0.0\nbin: tt\ninstall_dir: ~/.local/bin\n",
+		"dots.yaml":    "name: test-dots\ntype: repo\nowner: team\nrepo: dotfiles\ndir: ~/.dotfiles\nsource: company\n",
+		"pkgs.yaml":    "name: test-pkgs\ntype: package\npackages: [jq]\ncheck: 'true'\n",
+		"hello.yaml":   "name: test-hello\ntype: script\ninstall: echo hi\ncheck: 'false'\n",
+		"builtin.yaml": "name: chsrc\ntype: script\ninstall: echo hi\ncheck: 'true'\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{
+		Logger:   logger,
+		HomeDir:  "/home/u",
+		Settings: &config.Settings{Sources: map[string]config.SourceSettings{"company": {Type: "gitea", URL: "https://git.example.com"}}},
+	}
+	LoadManifests(dir, cfg)
+	defer func() {
+		factoriesMu.Lock()
+		defer factoriesMu.Unlock()
+		for _, name := range []string{"test-tool", "test-dots", "test-pkgs", "test-hello"} {
+			delete(factories, name)
+		}
+	}()
+
+	tests := []struct {
+		name         string
+		wantType     interface{}
+		wantHomepage string
+		wantInstall  bool
+	}{
+		{name: "test-tool", wantType: &ReleaseBinaryManager{}, wantHomepage: "https://github.com/team/tool"},
+		{name: "test-dots", wantType: &GitRepoManager{}, wantHomepage: "https://git.example.com/team/dotfiles"},
+		{name: "test-pkgs", wantType: &PackagesManager{}, wantInstall: true},
+		{name: "test-hello", wantType: &ScriptManager{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr, err := NewManager(tt.name, cfg)
+			if err != nil {
+				t.Fatalf("NewManager() error = %v", err)
+			}
+			if got, want := reflectTypeName(mgr), reflectTypeName(tt.wantType); got != want {
+				t.Errorf("管理器类型 = %s, want %s", got, want)
+			}
+			if got := mgr.GetMetadata().Homepage; got != tt.wantHomepage {
+				t.Errorf("Homepage = %s, want %s", got, tt.wantHomepage)
+			}
+			if got := mgr.IsInstalled(); got != tt.wantInstall {
+				t.Errorf("IsInstalled() = %v, want %v", got, tt.wantInstall)
+			}
+		})
+	}
+
+	// 与内置应用重名的定义被忽略
+	mgr, err := NewManager("chsrc", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mgr.(*ReleaseBinaryManager); !ok {
+		t.Errorf("内置的chsrc被应用定义覆盖")
+	}
+
+	rm := mustManager(t, "test-tool", cfg).(*ReleaseBinaryManager)
+	if got := rm.binPath(); got != "/home/u/.local/bin/tt" {
+		t.Errorf("binPath() = %s", got)
+	}
+}
+
+func reflectTypeName(v interface{}) string {
+	return fmt.Sprintf("%T", v)
+}
+
+func mustManager(t *testing.T, name string, cfg *config.Config) Manager {
+	t.Helper()
+	mgr, err := NewManager(name, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mgr
+}
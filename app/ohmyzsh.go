@@ -2,12 +2,23 @@ package app
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
 	"github.com/bookandmusic/envsetup/utils"
 )
 
+func init() {
+	Register("ohmyzsh", func(cfg *config.Config) Manager { return NewOhMyZshManager(cfg) })
+}
+
+// zshOptionPattern 匹配合法的plugins和theme选项值
+var zshOptionPattern = regexp.MustCompile(`^[A-Za-z0-9_ /-]*$`)
+
 type repo struct {
 	ower      string
 	repo      string
@@ -23,8 +34,7 @@ type OhMyZshManager struct {
 	repos      []*repo
 }
 
-func NewOhMyZshManager() *OhMyZshManager {
-	config := config.GetConfig()
+func NewOhMyZshManager(config *config.Config) *OhMyZshManager {
 	ohMyZshDir := fmt.Sprintf("%s/.oh-my-zsh", config.HomeDir)
 	pluginDir := fmt.Sprintf("%s/custom/plugins", ohMyZshDir)
 	themeDir := fmt.Sprintf("%s/custom/themes", ohMyZshDir)
@@ -58,7 +68,53 @@ func (v *OhMyZshManager) GetName() string {
 	return v.Name
 }
 
+func (v *OhMyZshManager) GetMetadata() *Metadata {
+	return &Metadata{
+		Name:        v.Name,
+		Description: "一个增强Zsh配置的开源框架,提供丰富的插件、主题和配置选项",
+		Homepage:    fmt.Sprintf("https://github.com/%s/%s", v.repos[0].ower, v.repos[0].repo),
+	}
+}
+
+func (v *OhMyZshManager) BundleResources(osType, arch string, flags *GlobalFlags) ([]utils.BundleResource, error) {
+	var resources []utils.BundleResource
+	for _, repo := range v.repos {
+		resources = append(resources, utils.BundleResource{Owner: repo.ower, Repo: repo.repo})
+	}
+	return resources, nil
+}
+
+func (v *OhMyZshManager) SystemPackages() []utils.SystemPackage {
+	return []utils.SystemPackage{{Name: "zsh", Command: "zsh"}}
+}
+
+func (v *OhMyZshManager) IsInstalled() bool {
+	return utils.DirectoryExists(v.ohMyZshDir)
+}
+
+func (v *OhMyZshManager) Status(flags *GlobalFlags) *AppStatus {
+	status := newAppStatus(v.Name, v.IsInstalled())
+	if head, err := utils.GetRepoHead(v.ohMyZshDir); err == nil {
+		status.Version = shortHash(head)
+	}
+	githubInfo := utils.NewGithubRepoInfo(
+		v.repos[0].ower, v.repos[0].repo,
+		flags.HttpProxy,
+		flags.GithubProxy,
+		v.config.Logger,
+	)
+	if head, err := githubInfo.GetRemoteHead(); err == nil {
+		status.Latest = shortHash(head)
+	} else {
+		v.config.Logger.Warnf("获取%s远程版本失败:%s", v.repos[0].repo, err)
+	}
+	return status
+}
+
 func (v *OhMyZshManager) Install(flags *GlobalFlags) error {
+	if _, _, err := v.zshrcOptions(flags); err != nil {
+		return err
+	}
 	installer, err := utils.GetInstaller(v.config.IsRoot, v.config.Logger)
 	if err != nil {
 		v.config.Logger.Errorf(err.Error())
@@ -68,20 +124,36 @@ func (v *OhMyZshManager) Install(flags *GlobalFlags) error {
 		return err
 	}
 
-	for _, repo := range v.repos {
-		githubInfo := utils.NewGithubRepoInfo(
-			repo.ower, repo.repo,
-			flags.HttpProxy,
-			flags.GithubProxy,
-			v.config.Logger,
-		)
-
-		if err := githubInfo.CloneRepo(repo.localPath); err != nil {
-			return err
+	tx := utils.NewTransaction("oh-my-zsh安装", v.config.IsRoot, v.config.Logger)
+	if err := v.installing(flags, tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			v.config.Logger.Error(rbErr)
 		}
+		return err
+	}
+	tx.Commit()
+
+	recordState(v.stateRecord())
+	v.config.Logger.Infof("成功安装zsh及oh-my-zsh!!!")
+	return nil
+}
+
+// installing 克隆oh-my-zsh及插件并生成~/.zshrc, 每一步都在tx中登记撤销操作
+func (v *OhMyZshManager) installing(flags *GlobalFlags, tx *utils.Transaction) error {
+	for _, repo := range v.repos {
+		tx.TrackCreated(repo.localPath, false)
+	}
+	tasks := v.repoTasks(flags, func(githubInfo *utils.GithubRepoInfo, localPath string) error {
+		return githubInfo.CloneRepo(localPath)
+	})
+	if err := utils.RunTasks(tasks, flags.Jobs, v.config.Logger); err != nil {
+		return err
 	}
 
 	zshrcPath := fmt.Sprintf("%s/.zshrc", v.config.HomeDir)
+	if err := tx.Backup(zshrcPath, false); err != nil {
+		return err
+	}
 	if utils.FileExists(zshrcPath) {
 		// 获取当前时间
 		currentTime := time.Now()
@@ -105,38 +177,114 @@ func (v *OhMyZshManager) Install(flags *GlobalFlags) error {
 	}
 	v.config.Logger.Infof("生成配置文件.zshrc成功!")
 
-	cmdStr = "sed -i 's/plugins=(git)/plugins=(git sudo zsh-autosuggestions zsh-syntax-highlighting)/' ~/.zshrc"
+	plugins, theme, err := v.zshrcOptions(flags)
+	if err != nil {
+		return err
+	}
+	sedExpr := fmt.Sprintf("s|plugins=(git)|plugins=(%s)|", plugins)
+	if theme != "" {
+		sedExpr = fmt.Sprintf("%s;s|^ZSH_THEME=.*|ZSH_THEME=\"%s\"|", sedExpr, theme)
+	}
+	cmdStr = fmt.Sprintf("sed -i '%s' ~/.zshrc", sedExpr)
 	if v.config.OS == "darwin" {
-		cmdStr = "sed -i '' 's/plugins=(git)/plugins=(git sudo zsh-autosuggestions zsh-syntax-highlighting)/' ~/.zshrc"
+		cmdStr = fmt.Sprintf("sed -i '' '%s' ~/.zshrc", sedExpr)
 	}
 	if err := utils.ExecCmd(cmdStr, v.config.Logger); err != nil {
 		v.config.Logger.Errorf("修改~/.zshrc配置文件启用插件失败!")
 		return err
 	}
 	v.config.Logger.Infof("修改~/.zshrc配置文件启用插件成功!")
-
-	v.config.Logger.Infof("成功安装zsh及oh-my-zsh!!!")
 	return nil
 }
 
+// zshrcOptions 返回写入~/.zshrc的plugins和theme选项。
+// 选项会拼接到sed表达式中, 只允许插件和主题名称中合法的字符
+func (v *OhMyZshManager) zshrcOptions(flags *GlobalFlags) (string, string, error) {
+	plugins := flags.GetOption("plugins", "git sudo zsh-autosuggestions zsh-syntax-highlighting")
+	theme := flags.GetOption("theme", "")
+	for name, value := range map[string]string{"plugins": plugins, "theme": theme} {
+		if !zshOptionPattern.MatchString(value) {
+			return "", "", fmt.Errorf("选项%s的值无效: %q, 只能包含字母、数字、下划线、横线、斜线和空格", name, value)
+		}
+	}
+	return plugins, theme, nil
+}
+
+// stateRecord 生成当前oh-my-zsh的安装记录, 版本为oh-my-zsh仓库的HEAD
+func (v *OhMyZshManager) stateRecord() *state.Record {
+	version, err := utils.GetRepoHead(v.ohMyZshDir)
+	if err != nil {
+		v.config.Logger.Debugf("获取%s版本失败: %s", v.ohMyZshDir, err)
+	}
+	paths := []string{fmt.Sprintf("%s/.zshrc", v.config.HomeDir)}
+	for _, repo := range v.repos {
+		paths = append(paths, repo.localPath)
+	}
+	return &state.Record{
+		Name:    v.Name,
+		Version: version,
+		Source:  fmt.Sprintf("https://github.com/%s/%s.git", v.repos[0].ower, v.repos[0].repo),
+		Files:   newFileRecords(paths...),
+	}
+}
+
 func (v *OhMyZshManager) Update(flags *GlobalFlags) error {
-	if !utils.DirectoryExists(v.ohMyZshDir) {
+	if !v.IsInstalled() {
 		v.config.Logger.Warn("oh-my-zsh尚未安装。请使用 'install' 命令首先安装它。")
 		return nil
 	}
+	tx := utils.NewTransaction("oh-my-zsh更新", v.config.IsRoot, v.config.Logger)
+	if err := v.updating(flags, tx); err != nil {
+		v.config.Logger.Errorf("oh-my-zsh更新失败!")
+		if rbErr := tx.Rollback(); rbErr != nil {
+			v.config.Logger.Error(rbErr)
+		}
+		return err
+	}
+	tx.Commit()
+	recordState(v.stateRecord())
+	return nil
+}
+
+// updating 拉取oh-my-zsh及插件的最新代码, 任一仓库失败时由tx将所有仓库和~/.zshrc恢复到更新前的状态
+func (v *OhMyZshManager) updating(flags *GlobalFlags, tx *utils.Transaction) error {
+	if err := tx.Backup(fmt.Sprintf("%s/.zshrc", v.config.HomeDir), false); err != nil {
+		return err
+	}
 	for _, repo := range v.repos {
-		githubInfo := utils.NewGithubRepoInfo(
-			repo.ower, repo.repo,
-			flags.HttpProxy,
-			flags.GithubProxy,
-			v.config.Logger,
-		)
-
-		if err := githubInfo.PullRepo(repo.localPath); err != nil {
+		if err := tx.BackupRepo(repo.localPath); err != nil {
 			return err
 		}
 	}
-	return nil
+	tasks := v.repoTasks(flags, func(githubInfo *utils.GithubRepoInfo, localPath string) error {
+		return githubInfo.PullRepo(localPath)
+	})
+	return utils.RunTasks(tasks, flags.Jobs, v.config.Logger)
+}
+
+// repoTasks 为每个仓库生成一个任务, 插件仓库位于oh-my-zsh仓库内, 需要等待其完成
+func (v *OhMyZshManager) repoTasks(flags *GlobalFlags, op func(githubInfo *utils.GithubRepoInfo, localPath string) error) []utils.Task {
+	var tasks []utils.Task
+	for i, repo := range v.repos {
+		repo := repo
+		task := utils.Task{
+			Name: fmt.Sprintf("%s/%s", repo.ower, repo.repo),
+			Run: func(logger *logrus.Logger) error {
+				githubInfo := utils.NewGithubRepoInfo(
+					repo.ower, repo.repo,
+					flags.HttpProxy,
+					flags.GithubProxy,
+					logger,
+				)
+				return op(githubInfo, repo.localPath)
+			},
+		}
+		if i > 0 {
+			task.Deps = []string{fmt.Sprintf("%s/%s", v.repos[0].ower, v.repos[0].repo)}
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
 }
 
 func (v *OhMyZshManager) Delete(flags *GlobalFlags) error {
@@ -147,6 +295,7 @@ func (v *OhMyZshManager) Delete(flags *GlobalFlags) error {
 		v.config.Logger.Errorf("删除~/.oh-my-zsh和~/.zshrc失败!")
 		return err
 	}
+	removeState(v.Name)
 	v.config.Logger.Infof("删除~/.oh-my-zsh和~/.zshrc成功!")
 	return nil
 }
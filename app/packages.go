@@ -0,0 +1,61 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// PackageDeclarer 由需要系统软件包的应用实现, 安装前会一次性安装所有应用声明的软件包
+type PackageDeclarer interface {
+	// SystemPackages 返回应用需要的系统软件包
+	SystemPackages() []utils.SystemPackage
+}
+
+// sudoKeepAliveInterval 小于sudo凭据默认的有效期(15分钟)
+const sudoKeepAliveInterval = 4 * time.Minute
+
+// PrepareSystemPackages 在安装managers前刷新一次软件源索引, 并用一条命令安装它们声明的系统软件包。
+// 需要提权时只验证一次凭据, 使用sudo时在后台定期刷新直到调用返回的stop
+func PrepareSystemPackages(managers []Manager) (stop func(), err error) {
+	cfg := config.GetConfig()
+	stop = func() {}
+
+	var packages []utils.SystemPackage
+	for _, mgr := range managers {
+		if declarer, ok := mgr.(PackageDeclarer); ok {
+			packages = append(packages, declarer.SystemPackages()...)
+		}
+	}
+	if len(packages) == 0 {
+		return stop, nil
+	}
+	installer, err := utils.GetInstaller(cfg.IsRoot, cfg.Logger)
+	if err != nil {
+		cfg.Logger.Errorf(err.Error())
+		return stop, err
+	}
+	missing := installer.MissingPackages(packages)
+	if len(missing) == 0 {
+		return stop, nil
+	}
+
+	if installer.GetIsRequiresSudo() && !cfg.IsRoot && !utils.IsDryRun() {
+		// 没有提权工具或非交互模式下需要密码时直接失败, 不逐个应用重试
+		if err := installer.InputSudoPasswd(); err != nil {
+			cfg.Logger.Errorf("无法安装系统软件包%s: %s", strings.Join(missing, " "), err)
+			return stop, err
+		}
+		stop = utils.KeepSudoAlive(sudoKeepAliveInterval, cfg.Logger)
+	}
+	cfg.Logger.Infof("开始使用%s安装系统软件包: %s", installer.GetPackageManager(), strings.Join(missing, " "))
+	if err := installer.Install(missing); err != nil {
+		cfg.Logger.Errorf("系统软件包安装失败,%s", err)
+		stop()
+		return func() {}, err
+	}
+	cfg.Logger.Infof("系统软件包安装成功!!!")
+	return stop, nil
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// RunParallel 按ordered中的依赖关系执行action, 相互独立的应用最多同时执行jobs个。
+// reverse 为 true 时依赖方先于被依赖方执行。并发执行时每个应用使用带独立日志的新实例,
+// 避免不同应用的输出交错
+func RunParallel(ordered []Manager, jobs int, reverse bool, action func(Manager) error) error {
+	cfg := config.GetConfig()
+	selected := make(map[string]bool, len(ordered))
+	for _, mgr := range ordered {
+		selected[mgr.GetName()] = true
+	}
+
+	// 计算每个应用在本批次中的前置应用
+	prereqs := make(map[string][]string, len(ordered))
+	for _, mgr := range ordered {
+		meta := mgr.GetMetadata()
+		for _, prev := range append(append([]string{}, meta.Dependencies...), meta.After...) {
+			if !selected[prev] {
+				continue
+			}
+			if reverse {
+				prereqs[prev] = append(prereqs[prev], mgr.GetName())
+			} else {
+				prereqs[mgr.GetName()] = append(prereqs[mgr.GetName()], prev)
+			}
+		}
+	}
+
+	if jobs > 1 && len(ordered) > 1 && !cfg.IsRoot && utils.PrivilegeTool() != "" {
+		// 并发执行时无法交互输入密码, 提前获取提权凭据
+		cfg.Logger.Infof("并发执行前验证%s权限", utils.PrivilegeTool())
+		if err := utils.ValidatePrivilege(cfg.IsRoot, cfg.Logger); err != nil {
+			cfg.Logger.Warnf("验证%s权限失败: %s", utils.PrivilegeTool(), err)
+		}
+	}
+
+	var tasks []utils.Task
+	for _, mgr := range ordered {
+		mgr := mgr
+		tasks = append(tasks, utils.Task{
+			Name: mgr.GetName(),
+			Deps: prereqs[mgr.GetName()],
+			Run: func(logger *logrus.Logger) error {
+				if logger == cfg.Logger {
+					return action(mgr)
+				}
+				isolated, err := NewManager(mgr.GetName(), cfg.WithLogger(logger))
+				if err != nil {
+					return err
+				}
+				return action(isolated)
+			},
+		})
+	}
+	return utils.RunTasks(tasks, jobs, cfg.Logger)
+}
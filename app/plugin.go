@@ -0,0 +1,270 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// PluginPrefix 是外部插件可执行文件名的前缀, 例如 envsetup-plugin-foo 提供应用 foo
+const PluginPrefix = "envsetup-plugin-"
+
+// PluginProtocolVersion 是插件协议的版本, 不兼容的修改需要增加版本号
+const PluginProtocolVersion = 1
+
+// 插件提供的命令
+const (
+	PluginMetadata    = "metadata"
+	PluginIsInstalled = "is_installed"
+	PluginStatus      = "status"
+	PluginInstall     = "install"
+	PluginUpdate      = "update"
+	PluginDelete      = "delete"
+)
+
+// 查询类命令的超时时间, 安装、更新和删除不限制
+const pluginQueryTimeout = 30 * time.Second
+
+var pluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// PluginRequest 是envsetup每次调用插件时写入其标准输入的JSON
+type PluginRequest struct {
+	Version int          `json:"version"`
+	Command string       `json:"command"`
+	Flags   PluginFlags  `json:"flags"`
+	Config  PluginConfig `json:"config"`
+}
+
+// PluginFlags 是传递给插件的命令行选项
+type PluginFlags struct {
+	Force       bool              `json:"force"`
+	Tag         string            `json:"tag"`
+	HttpsProxy  string            `json:"https_proxy"`
+	GithubProxy string            `json:"github_proxy"`
+	Options     map[string]string `json:"options,omitempty"`
+	Jobs        int               `json:"jobs"`
+	SkipVerify  bool              `json:"skip_verify"`
+	DryRun      bool              `json:"dry_run"`
+}
+
+// PluginConfig 是传递给插件的运行环境
+type PluginConfig struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	HomeDir   string `json:"home_dir"`
+	IsRoot    bool   `json:"is_root"`
+	DataDir   string `json:"data_dir"`
+	CacheDir  string `json:"cache_dir"`
+	ConfigDir string `json:"config_dir"`
+}
+
+// PluginResponse 是插件写到标准输出的JSON, 日志需要写到标准错误。
+// error 不为空或退出码不为0表示命令失败
+type PluginResponse struct {
+	Metadata  *Metadata `json:"metadata,omitempty"`
+	Installed bool      `json:"installed"`
+	Version   string    `json:"version,omitempty"`
+	Latest    string    `json:"latest,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// LoadPlugins 在PATH中查找外部插件并注册为应用, 同名的插件只使用PATH中靠前的一个
+func LoadPlugins(pathEnv string, cfg *config.Config) {
+	for name, path := range findPlugins(pathEnv) {
+		if isRegistered(name) {
+			cfg.Logger.Warnf("插件%s与已有应用重名, 已忽略", path)
+			continue
+		}
+		plugin := &plugin{name: name, path: path}
+		Register(name, func(cfg *config.Config) Manager { return &PluginManager{plugin: plugin, config: cfg} })
+	}
+}
+
+// findPlugins 返回PATH中的插件, 键为应用名称
+func findPlugins(pathEnv string) map[string]string {
+	plugins := make(map[string]string)
+	for _, dir := range filepath.SplitList(pathEnv) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Name(), PluginPrefix)
+			if name == entry.Name() || !pluginNamePattern.MatchString(name) {
+				continue
+			}
+			if _, ok := plugins[name]; ok {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+				continue
+			}
+			plugins[name] = path
+		}
+	}
+	return plugins
+}
+
+// plugin 是一个插件可执行文件, 元数据只查询一次
+type plugin struct {
+	name string
+	path string
+
+	once     sync.Once
+	metadata *Metadata
+}
+
+// PluginManager 通过插件协议调用外部插件管理应用
+type PluginManager struct {
+	plugin *plugin
+	config *config.Config
+}
+
+// call 向插件发送命令并解析其响应
+func (pm *PluginManager) call(command string, flags *GlobalFlags) (*PluginResponse, error) {
+	req := &PluginRequest{
+		Version: PluginProtocolVersion,
+		Command: command,
+		Flags: PluginFlags{
+			Force:       flags.Force,
+			Tag:         flags.Tag,
+			HttpsProxy:  flags.HttpProxy,
+			GithubProxy: flags.GithubProxy,
+			Options:     flags.Options,
+			Jobs:        flags.Jobs,
+			SkipVerify:  flags.SkipVerify,
+			DryRun:      utils.IsDryRun(),
+		},
+		Config: PluginConfig{
+			OS:        pm.config.OS,
+			Arch:      pm.config.ARCH,
+			HomeDir:   pm.config.HomeDir,
+			IsRoot:    pm.config.IsRoot,
+			DataDir:   pm.config.DataDir,
+			CacheDir:  pm.config.CacheDir,
+			ConfigDir: pm.config.ConfigDir,
+		},
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	readOnly := command == PluginMetadata || command == PluginIsInstalled || command == PluginStatus
+	var timeout time.Duration
+	if readOnly {
+		timeout = pluginQueryTimeout
+	}
+	output, runErr := utils.ExecWithInput(pm.plugin.path, []string{command}, input, readOnly, timeout, pm.config.Logger)
+	if !readOnly && utils.IsDryRun() {
+		return &PluginResponse{}, nil
+	}
+
+	resp := &PluginResponse{}
+	if err := json.Unmarshal(output, resp); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("插件%s执行%s失败: %w", pm.plugin.path, command, runErr)
+		}
+		return nil, fmt.Errorf("插件%s执行%s的输出不是有效的JSON: %w", pm.plugin.path, command, err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("插件%s执行%s失败: %w", pm.plugin.path, command, runErr)
+	}
+	return resp, nil
+}
+
+func (pm *PluginManager) GetName() string {
+	return pm.plugin.name
+}
+
+// GetMetadata 返回插件提供的元数据, 查询失败时只使用插件名称
+func (pm *PluginManager) GetMetadata() *Metadata {
+	pm.plugin.once.Do(func() {
+		meta := &Metadata{Description: fmt.Sprintf("外部插件 %s", pm.plugin.path)}
+		if resp, err := pm.call(PluginMetadata, &GlobalFlags{}); err != nil {
+			pm.config.Logger.Warnf("获取插件%s的信息失败: %s", pm.plugin.path, err)
+		} else if resp.Metadata != nil {
+			meta = resp.Metadata
+		}
+		// 应用名称总是由文件名决定
+		meta.Name = pm.plugin.name
+		pm.plugin.metadata = meta
+	})
+	return pm.plugin.metadata
+}
+
+func (pm *PluginManager) IsInstalled() bool {
+	resp, err := pm.call(PluginIsInstalled, &GlobalFlags{})
+	if err != nil {
+		pm.config.Logger.Warn(err)
+		return false
+	}
+	return resp.Installed
+}
+
+func (pm *PluginManager) Status(flags *GlobalFlags) *AppStatus {
+	resp, err := pm.call(PluginStatus, flags)
+	if err != nil {
+		pm.config.Logger.Warn(err)
+		return newAppStatus(pm.plugin.name, false)
+	}
+	status := newAppStatus(pm.plugin.name, resp.Installed)
+	if resp.Version != "" {
+		status.Version = resp.Version
+	}
+	status.Latest = resp.Latest
+	return status
+}
+
+// run 执行安装或更新, 插件不支持回滚, 失败时由插件自行清理
+func (pm *PluginManager) run(command, action string, flags *GlobalFlags) error {
+	return runInstalling(pm.config, pm.plugin.name, action, func(tx *utils.Transaction) (*state.Record, error) {
+		resp, err := pm.call(command, flags)
+		if err != nil {
+			return nil, err
+		}
+		return &state.Record{Name: pm.plugin.name, Version: resp.Version, Source: pm.plugin.path}, nil
+	})
+}
+
+func (pm *PluginManager) Install(flags *GlobalFlags) error {
+	if !flags.Force && pm.IsInstalled() {
+		pm.config.Logger.Warnf("%s已经安装。使用 -f 选项强制重新安装。", pm.plugin.name)
+		return nil
+	}
+	return pm.run(PluginInstall, "安装", flags)
+}
+
+func (pm *PluginManager) Update(flags *GlobalFlags) error {
+	if !pm.IsInstalled() {
+		pm.config.Logger.Warnf("%s尚未安装。请使用 'install' 命令首先安装它。", pm.plugin.name)
+		return nil
+	}
+	return pm.run(PluginUpdate, "更新", flags)
+}
+
+func (pm *PluginManager) Delete(flags *GlobalFlags) error {
+	pm.config.Logger.Infof("开始删除%s...", pm.plugin.name)
+	if _, err := pm.call(PluginDelete, flags); err != nil {
+		pm.config.Logger.Errorf("%s删除失败!", pm.plugin.name)
+		return err
+	}
+	removeState(pm.plugin.name)
+	pm.config.Logger.Infof("%s删除成功!", pm.plugin.name)
+	return nil
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/config"
+)
+
+// demoPlugin 把请求保存到 request.json, 安装时创建 installed 文件
+const demoPlugin = `#!/bin/sh
+dir=$(dirname "$0")
+cat > "$dir/request.json"
+echo "插件日志" >&2
+case "$1" in
+metadata) echo '{"metadata":{"name":"other","description":"演示插件","homepage":"https://example.com"}}' ;;
+is_installed|status)
+	if [ -f "$dir/installed" ]; then echo '{"installed":true,"version":"1.0.0","latest":"1.1.0"}'; else echo '{"installed":false}'; fi ;;
+install) touch "$dir/installed"; echo '{"version":"1.0.0"}' ;;
+update) echo '{"error":"没有可用的更新"}' ;;
+delete) exit 3 ;;
+esac
+`
+
+func writePlugin(t *testing.T, dir, name, content string, perm os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFindPlugins(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	want := writePlugin(t, first, PluginPrefix+"demo", demoPlugin, 0o755)
+	writePlugin(t, second, PluginPrefix+"demo", demoPlugin, 0o755)
+	writePlugin(t, second, PluginPrefix+"noexec", demoPlugin, 0o644)
+	writePlugin(t, second, PluginPrefix+"Bad Name", demoPlugin, 0o755)
+	writePlugin(t, second, "envsetup-other", demoPlugin, 0o755)
+	if err := os.Mkdir(filepath.Join(second, PluginPrefix+"dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins := findPlugins(strings.Join([]string{first, filepath.Join(first, "missing"), second}, string(os.PathListSeparator)))
+	if len(plugins) != 1 || plugins["demo"] != want {
+		t.Errorf("findPlugins() = %v, want 只有PATH中靠前的demo", plugins)
+	}
+}
+
+func TestPluginManager(t *testing.T) {
+	dir := t.TempDir()
+	path := writePlugin(t, dir, PluginPrefix+"demo", demoPlugin, 0o755)
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	cfg := &config.Config{Logger: logger, OS: "linux", ARCH: "amd64", HomeDir: "/home/u", ConfigDir: "/home/u/.config/envsetup"}
+	pm := &PluginManager{plugin: &plugin{name: "demo", path: path}, config: cfg}
+
+	meta := pm.GetMetadata()
+	if meta.Name != "demo" || meta.Description != "演示插件" || meta.Homepage != "https://example.com" {
+		t.Errorf("GetMetadata() = %+v, 名称应由文件名决定", meta)
+	}
+	if pm.IsInstalled() {
+		t.Errorf("IsInstalled() = true, want false")
+	}
+
+	resp, err := pm.call(PluginInstall, &GlobalFlags{Tag: "v1", HttpProxy: "http://proxy:7890", Options: map[string]string{"k": "v"}})
+	if err != nil {
+		t.Fatalf("call(install) error = %v", err)
+	}
+	// 请求中包含协议版本、命令、选项和运行环境
+	content, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"version":1`, `"command":"install"`, `"tag":"v1"`, `"https_proxy":"http://proxy:7890"`,
+		`"options":{"k":"v"}`, `"os":"linux"`, `"home_dir":"/home/u"`, `"config_dir":"/home/u/.config/envsetup"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("请求 %s 中缺少 %s", content, want)
+		}
+	}
+	if resp.Version != "1.0.0" || !pm.IsInstalled() {
+		t.Errorf("call(install) = %+v", resp)
+	}
+
+	if _, err := pm.call(PluginUpdate, &GlobalFlags{}); err == nil || err.Error() != "没有可用的更新" {
+		t.Errorf("call(update) error = %v, want 插件返回的错误", err)
+	}
+	if _, err := pm.call(PluginDelete, &GlobalFlags{}); err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("call(delete) error = %v, want 退出码错误", err)
+	}
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/bookandmusic/envsetup/config"
+)
+
+// ApplyProfile 将本机环境收敛到配置文件描述的状态。
+// 先按依赖的逆序删除 absent 的应用, 再按依赖顺序安装或更新其余应用,
+// 配置文件中未列出但被依赖的应用会以 present 状态自动加入, 相互独立的应用最多同时执行jobs个
+func ApplyProfile(profile *config.Profile, managers []Manager, jobs int) error {
+	cfg := config.GetConfig()
+
+	byName := make(map[string]Manager, len(managers))
+	for _, mgr := range managers {
+		byName[mgr.GetName()] = mgr
+	}
+	// 先校验所有应用, 避免执行到一半才发现配置有误
+	items := make(map[string]config.ProfileApp, len(profile.Apps))
+	var absent, present []string
+	for _, item := range profile.Apps {
+		if _, ok := byName[item.Name]; !ok {
+			return fmt.Errorf("配置文件中的应用%s不存在", item.Name)
+		}
+		items[item.Name] = item
+		if item.State == config.StateAbsent {
+			absent = append(absent, item.Name)
+		} else {
+			present = append(present, item.Name)
+		}
+	}
+
+	removing, err := ResolveOrder(managers, absent)
+	if err != nil {
+		return err
+	}
+	installing, err := ResolveOrder(managers, present)
+	if err != nil {
+		return err
+	}
+	for _, mgr := range installing {
+		if item, ok := items[mgr.GetName()]; ok && item.State == config.StateAbsent {
+			return fmt.Errorf("应用%s被其他应用依赖, 不能设置为absent", mgr.GetName())
+		}
+	}
+
+	for _, mgr := range Reverse(removing) {
+		item, ok := items[mgr.GetName()]
+		if !ok {
+			// 依赖只随被删除的应用一起排序, 不会被删除
+			continue
+		}
+		if err := applyItem(mgr, item, jobs); err != nil {
+			return err
+		}
+	}
+	stop, err := PrepareSystemPackages(installing)
+	if err != nil {
+		return err
+	}
+	defer stop()
+	err = RunParallel(installing, jobs, false, func(mgr Manager) error {
+		item, ok := items[mgr.GetName()]
+		if !ok {
+			item = config.ProfileApp{
+				Name:        mgr.GetName(),
+				State:       config.StatePresent,
+				HttpsProxy:  profile.HttpsProxy,
+				GithubProxy: profile.GithubProxy,
+			}
+		}
+		return applyItem(mgr, item, jobs)
+	})
+	if err != nil {
+		return err
+	}
+	cfg.Logger.Infof("配置文件应用完成!")
+	return nil
+}
+
+// applyItem 将单个应用收敛到期望状态
+func applyItem(mgr Manager, item config.ProfileApp, jobs int) error {
+	logger := config.GetConfig().Logger
+	flags := &GlobalFlags{
+		Force:       item.Force,
+		Tag:         item.Tag,
+		HttpProxy:   item.HttpsProxy,
+		GithubProxy: item.GithubProxy,
+		Options:     item.Options,
+		Jobs:        jobs,
+		SkipVerify:  item.SkipVerify,
+	}
+
+	var err error
+	installed := mgr.IsInstalled()
+	switch item.State {
+	case config.StatePresent:
+		if installed && !item.Force {
+			// 指定了tag时, 已安装的版本与tag不一致需要重新安装
+			rec, ok := getStore().Get(item.Name)
+			if item.Tag == "" || (ok && rec.Version == item.Tag) {
+				logger.Infof("%s已安装,跳过", item.Name)
+				return nil
+			}
+			version := "未知"
+			if ok && rec.Version != "" {
+				version = rec.Version
+			}
+			logger.Infof("%s已安装的版本%s与配置文件指定的%s不一致,重新安装", item.Name, version, item.Tag)
+			flags.Force = true
+		}
+		err = mgr.Install(flags)
+	case config.StateLatest:
+		if installed {
+			err = mgr.Update(flags)
+		} else {
+			err = mgr.Install(flags)
+		}
+	case config.StateAbsent:
+		if !installed {
+			logger.Infof("%s未安装,跳过", item.Name)
+			return nil
+		}
+		err = mgr.Delete(flags)
+	}
+	if err != nil {
+		return fmt.Errorf("应用%s收敛到%s状态失败: %w", item.Name, item.State, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bookandmusic/envsetup/config"
+)
+
+// Metadata 描述一个应用的基本信息, 命令行的列表和帮助信息都由它生成
+type Metadata struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Homepage     string   `json:"homepage"`
+	OS           []string `json:"os,omitempty"`           // 支持的操作系统, 为空表示不限
+	Arch         []string `json:"arch,omitempty"`         // 支持的CPU架构, 为空表示不限
+	Dependencies []string `json:"dependencies,omitempty"` // 必须先安装的应用, 会被自动加入
+	After        []string `json:"after,omitempty"`        // 同时安装时需要排在其后的应用
+	DefaultTag   string   `json:"default_tag,omitempty"`
+}
+
+// Supports 判断应用是否支持指定的操作系统和架构
+func (m *Metadata) Supports(osType, arch string) bool {
+	return matchAny(m.OS, osType) && matchAny(m.Arch, arch)
+}
+
+func matchAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Factory 使用指定的配置创建一个应用管理器
+type Factory func(cfg *config.Config) Manager
+
+var (
+	factories   = make(map[string]Factory)
+	factoriesMu sync.Mutex
+)
+
+// Register 注册一个应用管理器, 内置应用在各自文件的 init 中注册
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+func isRegistered(name string) bool {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	_, ok := factories[name]
+	return ok
+}
+
+// NewManager 使用指定的配置创建已注册的应用管理器
+func NewManager(name string, cfg *config.Config) (Manager, error) {
+	factoriesMu.Lock()
+	factory, ok := factories[name]
+	factoriesMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("应用%s不存在", name)
+	}
+	return factory(cfg), nil
+}
+
+// Managers 返回所有支持当前平台的应用管理器, 按名称排序
+func Managers() []Manager {
+	cfg := config.GetConfig()
+
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	var managers []Manager
+	for _, factory := range factories {
+		mgr := factory(cfg)
+		if !mgr.GetMetadata().Supports(cfg.OS, cfg.ARCH) {
+			continue
+		}
+		managers = append(managers, mgr)
+	}
+	sort.Slice(managers, func(i, j int) bool {
+		return managers[i].GetName() < managers[j].GetName()
+	})
+	return managers
+}
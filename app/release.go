@@ -0,0 +1,42 @@
+package app
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// resolveRelease 确定要安装的版本及适用于osType/arch的Release文件名。
+// 指定了 --tag 时使用该版本, 否则使用最新版本; 无法获取Release信息时退回默认版本和
+// rule.Fallback 推测的文件名, 并明确告知用户
+func resolveRelease(githubInfo *utils.GithubRepoInfo, flags *GlobalFlags, defaultTag string, rule *utils.AssetRule, osType, arch string, logger *logrus.Logger) (string, string, error) {
+	var release *utils.Release
+	var err error
+	if flags.Tag != "" {
+		release, err = githubInfo.GetReleaseByTag(flags.Tag)
+	} else {
+		release, err = githubInfo.GetLatestRelease()
+	}
+	if err != nil {
+		if rule.Fallback == nil {
+			return "", "", err
+		}
+		tagName := flags.Tag
+		if tagName == "" {
+			tagName = defaultTag
+			logger.Warnf("%s, 将使用内置的默认版本%s, 它可能不是最新版本。可使用 --tag 指定版本", err, defaultTag)
+		} else {
+			logger.Warn(err)
+		}
+		assetName := rule.Fallback(tagName, osType, arch)
+		logger.Warnf("无法获取Release文件列表, 使用推测的文件名%s", assetName)
+		return tagName, assetName, nil
+	}
+
+	asset, err := rule.Match(release, osType, arch)
+	if err != nil {
+		return "", "", err
+	}
+	logger.Infof("%s适用于%s/%s的文件:%s", release.TagName, osType, arch, asset.Name)
+	return release.TagName, asset.Name, nil
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+var (
+	store     *state.Store
+	storeOnce sync.Once
+)
+
+// getStore 获取本地安装状态库, 状态文件损坏时使用空状态库继续执行
+func getStore() *state.Store {
+	storeOnce.Do(func() {
+		cfg := config.GetConfig()
+		path := filepath.Join(cfg.DataDir, "state.json")
+		s, err := state.Open(path)
+		if err != nil {
+			cfg.Logger.Warnf("%s, 将忽略已有的安装记录", err)
+			s = state.NewStore(path)
+		}
+		store = s
+	})
+	return store
+}
+
+// recordState 记录应用的安装状态, 记录失败不影响安装结果
+func recordState(rec *state.Record) {
+	if utils.IsDryRun() {
+		return
+	}
+	if err := getStore().Put(rec); err != nil {
+		config.GetConfig().Logger.Warnf("记录%s安装状态失败: %s", rec.Name, err)
+	}
+}
+
+// runInstalling 在事务中执行installing, 失败时回滚, 成功后记录安装状态。action 为 "安装" 或 "更新"
+func runInstalling(cfg *config.Config, name, action string, installing func(tx *utils.Transaction) (*state.Record, error)) error {
+	cfg.Logger.Infof("开始%s%s...", action, name)
+	tx := utils.NewTransaction(name+action, cfg.IsRoot, cfg.Logger)
+	rec, err := installing(tx)
+	if err != nil {
+		cfg.Logger.Errorf("%s%s失败!", name, action)
+		if rbErr := tx.Rollback(); rbErr != nil {
+			cfg.Logger.Error(rbErr)
+		}
+		return err
+	}
+	tx.Commit()
+	recordState(rec)
+	cfg.Logger.Infof("%s%s成功!", name, action)
+	return nil
+}
+
+// removeState 删除应用的安装记录
+func removeState(name string) {
+	if utils.IsDryRun() {
+		return
+	}
+	if err := getStore().Remove(name); err != nil {
+		config.GetConfig().Logger.Warnf("删除%s安装记录失败: %s", name, err)
+	}
+}
+
+// newFileRecords 为一组路径生成文件记录
+func newFileRecords(paths ...string) []state.FileRecord {
+	records := make([]state.FileRecord, 0, len(paths))
+	for _, path := range paths {
+		records = append(records, state.NewFileRecord(path))
+	}
+	return records
+}
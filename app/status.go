@@ -0,0 +1,87 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bookandmusic/envsetup/state"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// AppStatus 描述一个应用在本机的实际状态
+type AppStatus struct {
+	Name      string   `json:"name"`
+	Installed bool     `json:"installed"`
+	Version   string   `json:"version"`
+	Latest    string   `json:"latest"`
+	Outdated  bool     `json:"outdated"`
+	Managed   bool     `json:"managed"`
+	Drift     []string `json:"drift,omitempty"`
+}
+
+// GetStatus 获取应用状态, 并判断是否有可用更新
+func GetStatus(mgr Manager, flags *GlobalFlags) *AppStatus {
+	status := mgr.Status(flags)
+	if status.Installed && status.Version != "" && status.Latest != "" {
+		status.Outdated = strings.TrimPrefix(status.Version, "v") != strings.TrimPrefix(status.Latest, "v")
+	}
+	return status
+}
+
+// newAppStatus 根据安装记录生成应用状态, 并检测本地文件是否发生漂移
+func newAppStatus(name string, installed bool) *AppStatus {
+	status := &AppStatus{
+		Name:      name,
+		Installed: installed,
+	}
+	rec, ok := getStore().Get(name)
+	if !ok {
+		return status
+	}
+	status.Managed = true
+	status.Version = rec.Version
+	status.Drift = detectDrift(rec)
+	return status
+}
+
+// detectDrift 对比安装记录与本地文件, 返回发生变化的项
+func detectDrift(rec *state.Record) []string {
+	var drift []string
+	for _, file := range rec.Files {
+		if _, err := os.Lstat(file.Path); err != nil {
+			drift = append(drift, fmt.Sprintf("文件缺失: %s", file.Path))
+			continue
+		}
+		if file.SHA256 == "" {
+			continue
+		}
+		if sum, err := state.FileSHA256(file.Path); err == nil && sum != file.SHA256 {
+			drift = append(drift, fmt.Sprintf("文件已修改: %s", file.Path))
+		}
+	}
+	for _, block := range rec.RcBlocks {
+		content, err := os.ReadFile(block.File)
+		if err != nil || !strings.Contains(string(content), block.Start) || !strings.Contains(string(content), block.End) {
+			drift = append(drift, fmt.Sprintf("配置块缺失: %s", block.File))
+		}
+	}
+	return drift
+}
+
+// shortHash 截取提交哈希的前7位用于展示
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// commandVersion 执行命令获取已安装的版本号
+func commandVersion(cmdStr string) string {
+	output, err := utils.CmdOutput(cmdStr)
+	if err != nil {
+		return ""
+	}
+	return utils.ParseVersion(output)
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// verifyDownload 校验Release下载文件的SHA256, checksums 为内置的哈希值, 键为 "tag/文件名"。
+// 哈希不一致时拒绝安装, 除非指定了 --skip-verify; Release未发布校验和时给出警告
+func verifyDownload(githubInfo *utils.GithubRepoInfo, filePath, srcFileName, tagName string, checksums map[string]string, flags *GlobalFlags, logger *logrus.Logger) error {
+	if flags.SkipVerify {
+		logger.Warnf("已指定--skip-verify, 跳过文件%s的SHA256校验", srcFileName)
+		return nil
+	}
+	pinned := checksums[fmt.Sprintf("%s/%s", tagName, srcFileName)]
+	err := githubInfo.VerifyReleaseFile(filePath, srcFileName, tagName, pinned)
+	if errors.Is(err, utils.ErrNoChecksum) {
+		logger.Warnf("%s %s未发布SHA256校验和, 无法校验文件%s", tagName, srcFileName, srcFileName)
+		return nil
+	}
+	if err != nil {
+		// 缓存的文件可能已被篡改, 删除后下次重新下载
+		githubInfo.EvictCachedFile(srcFileName, tagName)
+		logger.Errorf("%s, 拒绝安装。如确认文件可信, 可使用 --skip-verify 跳过校验", err)
+		return err
+	}
+	return nil
+}
@@ -4,9 +4,14 @@ import (
 	"fmt"
 
 	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
 	"github.com/bookandmusic/envsetup/utils"
 )
 
+func init() {
+	Register("vimrc", func(cfg *config.Config) Manager { return NewVimrcManager(cfg) })
+}
+
 type VimrcManager struct {
 	Name     string
 	ower     string
@@ -15,9 +20,7 @@ type VimrcManager struct {
 	vimrcDir string
 }
 
-func NewVimrcManager() *VimrcManager {
-	config := config.GetConfig()
-
+func NewVimrcManager(config *config.Config) *VimrcManager {
 	vimrcDir := fmt.Sprintf("%s/.vim_runtime", config.HomeDir)
 	return &VimrcManager{
 		Name:     "vimrc",
@@ -32,6 +35,45 @@ func (v *VimrcManager) GetName() string {
 	return v.Name
 }
 
+func (v *VimrcManager) GetMetadata() *Metadata {
+	return &Metadata{
+		Name:        v.Name,
+		Description: "Vim编辑器的配置文件,用于定制编辑器的行为和外观",
+		Homepage:    fmt.Sprintf("https://github.com/%s/%s", v.ower, v.repo),
+	}
+}
+
+func (v *VimrcManager) BundleResources(osType, arch string, flags *GlobalFlags) ([]utils.BundleResource, error) {
+	return []utils.BundleResource{{Owner: v.ower, Repo: v.repo}}, nil
+}
+
+func (v *VimrcManager) SystemPackages() []utils.SystemPackage {
+	return []utils.SystemPackage{{Name: "vim", Command: "vim"}}
+}
+
+func (v *VimrcManager) IsInstalled() bool {
+	return utils.DirectoryExists(v.vimrcDir)
+}
+
+func (v *VimrcManager) Status(flags *GlobalFlags) *AppStatus {
+	status := newAppStatus(v.Name, v.IsInstalled())
+	if head, err := utils.GetRepoHead(v.vimrcDir); err == nil {
+		status.Version = shortHash(head)
+	}
+	githubInfo := utils.NewGithubRepoInfo(
+		v.ower, v.repo,
+		flags.HttpProxy,
+		flags.GithubProxy,
+		v.config.Logger,
+	)
+	if head, err := githubInfo.GetRemoteHead(); err == nil {
+		status.Latest = shortHash(head)
+	} else {
+		v.config.Logger.Warnf("获取%s远程版本失败:%s", v.repo, err)
+	}
+	return status
+}
+
 func (v *VimrcManager) Install(flags *GlobalFlags) error {
 	installer, err := utils.GetInstaller(v.config.IsRoot, v.config.Logger)
 	if err != nil {
@@ -41,6 +83,23 @@ func (v *VimrcManager) Install(flags *GlobalFlags) error {
 	if err := installer.CheckInstall("vim", "vim"); err != nil {
 		return err
 	}
+	tx := utils.NewTransaction("vimrc安装", v.config.IsRoot, v.config.Logger)
+	if err := v.installing(flags, tx); err != nil {
+		v.config.Logger.Errorf("vimrv安装失败!")
+		if rbErr := tx.Rollback(); rbErr != nil {
+			v.config.Logger.Error(rbErr)
+		}
+		return err
+	}
+	tx.Commit()
+
+	recordState(v.stateRecord())
+	v.config.Logger.Infof("vimrv安装成功!")
+	return nil
+}
+
+// installing 克隆vimrc并执行其安装脚本, 每一步都在tx中登记撤销操作
+func (v *VimrcManager) installing(flags *GlobalFlags, tx *utils.Transaction) error {
 	githubInfo := utils.NewGithubRepoInfo(
 		v.ower, v.repo,
 		flags.HttpProxy,
@@ -48,19 +107,54 @@ func (v *VimrcManager) Install(flags *GlobalFlags) error {
 		v.config.Logger,
 	)
 
+	tx.TrackCreated(v.vimrcDir, false)
 	if err := githubInfo.CloneRepo(v.vimrcDir); err != nil {
 		return err
 	}
+	if err := tx.Backup(fmt.Sprintf("%s/.vimrc", v.config.HomeDir), false); err != nil {
+		return err
+	}
 	cmdStr := "sh ~/.vim_runtime/install_awesome_vimrc.sh"
 	if err := utils.ExecCmd(cmdStr, v.config.Logger); err != nil {
-		v.config.Logger.Errorf("vimrv安装失败!")
 		return err
 	}
-	v.config.Logger.Infof("vimrv安装成功!")
 	return nil
 }
 
+// stateRecord 生成当前vimrc的安装记录, 版本为仓库的HEAD
+func (v *VimrcManager) stateRecord() *state.Record {
+	version, err := utils.GetRepoHead(v.vimrcDir)
+	if err != nil {
+		v.config.Logger.Debugf("获取%s版本失败: %s", v.vimrcDir, err)
+	}
+	return &state.Record{
+		Name:    v.Name,
+		Version: version,
+		Source:  fmt.Sprintf("https://github.com/%s/%s.git", v.ower, v.repo),
+		Files:   newFileRecords(v.vimrcDir, fmt.Sprintf("%s/.vimrc", v.config.HomeDir)),
+	}
+}
+
 func (v *VimrcManager) Update(flags *GlobalFlags) error {
+	if !v.IsInstalled() {
+		v.config.Logger.Warn("vimrc尚未安装。请使用 'install' 命令首先安装它。")
+		return nil
+	}
+	tx := utils.NewTransaction("vimrc更新", v.config.IsRoot, v.config.Logger)
+	if err := v.updating(flags, tx); err != nil {
+		v.config.Logger.Errorf("vimrc更新失败!")
+		if rbErr := tx.Rollback(); rbErr != nil {
+			v.config.Logger.Error(rbErr)
+		}
+		return err
+	}
+	tx.Commit()
+	recordState(v.stateRecord())
+	return nil
+}
+
+// updating 拉取vimrc并更新插件, 插件也保存在vimrc仓库中, 失败时由tx将仓库和~/.vimrc恢复到更新前的状态
+func (v *VimrcManager) updating(flags *GlobalFlags, tx *utils.Transaction) error {
 	githubInfo := utils.NewGithubRepoInfo(
 		v.ower, v.repo,
 		flags.HttpProxy,
@@ -68,17 +162,36 @@ func (v *VimrcManager) Update(flags *GlobalFlags) error {
 		v.config.Logger,
 	)
 
+	if err := tx.Backup(fmt.Sprintf("%s/.vimrc", v.config.HomeDir), false); err != nil {
+		return err
+	}
+	if err := tx.BackupRepo(v.vimrcDir); err != nil {
+		return err
+	}
 	if err := githubInfo.PullRepo(v.vimrcDir); err != nil {
 		return err
 	}
 
+	var python string
+	if utils.IsCommandAvailable("python") {
+		python = "python"
+	} else if utils.IsCommandAvailable("python3") {
+		python = "python3"
+	} else {
+		v.config.Logger.Errorf("系统中不存在python解释器，无法更新插件")
+		return nil
+	}
+
 	var cmdStr string
 	updatePluginFile := "~/.vim_runtime/update_plugins.py"
 	tmpUpdatePluginFile := "~/.vim_runtime/update_plugins-bak.py"
-	if flags.GithubProxy != "" {
-		cmdStr = fmt.Sprintf("cp %s %s && sed -i 's|https://github.com|https://mirror.ghproxy.com/https://github.com|g' %s", updatePluginFile, tmpUpdatePluginFile, updatePluginFile)
+	// 使用实际生效的GitHub代理, --github-proxy=auto 时为测速选出的镜像
+	githubProxy := githubInfo.GithubProxy()
+	if githubProxy != "" {
+		sedExpr := fmt.Sprintf("s|https://github.com|%s|g", utils.JoinURL(githubProxy, "https://github.com"))
+		cmdStr = fmt.Sprintf("cp %s %s && sed -i '%s' %s", updatePluginFile, tmpUpdatePluginFile, sedExpr, updatePluginFile)
 		if v.config.OS == "darwin" {
-			cmdStr = fmt.Sprintf("cp %s %s && sed -i '' 's|https://github.com|https://mirror.ghproxy.com/https://github.com|g' %s", updatePluginFile, tmpUpdatePluginFile, updatePluginFile)
+			cmdStr = fmt.Sprintf("cp %s %s && sed -i '' '%s' %s", updatePluginFile, tmpUpdatePluginFile, sedExpr, updatePluginFile)
 		}
 		if err := utils.ExecCmd(cmdStr, v.config.Logger); err != nil {
 			v.config.Logger.Errorf("更新GitHub镜像地址失败!")
@@ -91,31 +204,22 @@ func (v *VimrcManager) Update(flags *GlobalFlags) error {
 	if flags.HttpProxy != "" {
 		cmdStr = fmt.Sprintf("export https_proxy=%s && ", flags.HttpProxy)
 	}
-	if utils.IsCommandAvailable("python") {
-		cmdStr = cmdStr + "python ~/.vim_runtime/update_plugins.py"
-	} else if utils.IsCommandAvailable("python3") {
-		cmdStr = cmdStr + "python3 ~/.vim_runtime/update_plugins.py"
-	} else {
-		v.config.Logger.Errorf("系统中不存在python解释器，无法更新插件")
-		return nil
-	}
-	var err error
-	err = utils.ExecCmd(cmdStr, v.config.Logger)
-	if err != nil {
+	cmdStr = cmdStr + python + " ~/.vim_runtime/update_plugins.py"
+	if err := utils.ExecCmd(cmdStr, v.config.Logger); err != nil {
 		v.config.Logger.Errorf("更新插件失败!")
-	} else {
-		v.config.Logger.Infof("更新插件成功!")
+		return err
 	}
+	v.config.Logger.Infof("更新插件成功!")
 
-	if flags.GithubProxy != "" {
+	if githubProxy != "" {
 		cmdStr = fmt.Sprintf("mv %s %s", tmpUpdatePluginFile, updatePluginFile)
-		if err = utils.ExecCmd(cmdStr, v.config.Logger); err != nil {
+		if err := utils.ExecCmd(cmdStr, v.config.Logger); err != nil {
 			v.config.Logger.Errorf("恢复原始插件文件失败!")
 			return err
 		}
 		v.config.Logger.Infof("恢复原始插件文件成功!")
 	}
-	return err
+	return nil
 }
 
 func (v *VimrcManager) Delete(flags *GlobalFlags) error {
@@ -126,6 +230,7 @@ func (v *VimrcManager) Delete(flags *GlobalFlags) error {
 		v.config.Logger.Errorf("删除~/.vim_runtime和~/.vimrc失败!")
 		return err
 	}
+	removeState(v.Name)
 	v.config.Logger.Infof("删除~/.vim_runtime和~/.vimrc成功!")
 	return nil
 }
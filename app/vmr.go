@@ -2,88 +2,58 @@ package app
 
 import (
 	"fmt"
-	"os"
-
-	archiver "github.com/mholt/archiver/v3"
 
 	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/state"
 	"github.com/bookandmusic/envsetup/utils"
 )
 
-// Define VMRManager to handleVMRoperations
-type VMRManager struct {
-	Name    string
-	ower    string
-	repo    string
-	tagName string
-	config  *config.Config
-	vmrDir  string
+func init() {
+	Register("vmr", func(cfg *config.Config) Manager { return NewVMRManager(cfg) })
 }
 
-func NewVMRManager() *VMRManager {
-	config := config.GetConfig()
-
-	vmrDir := fmt.Sprintf("%s/.vmr", config.HomeDir)
-	return &VMRManager{
-		Name:    "vmr",
-		ower:    "gvcgo",
-		tagName: "v0.6.5",
-		repo:    "version-manager",
-		config:  config,
-		vmrDir:  vmrDir,
-	}
+// VMR的可执行文件按 ReleaseBinary 安装, 之后再生成配置和启动脚本
+var vmrBinary = &ReleaseBinary{
+	Name:        "vmr",
+	Description: "一个简单、跨平台的版本管理器,用于管理多种 SDK 及其他工具",
+	Owner:       "gvcgo",
+	Repo:        "version-manager",
+	DefaultTag:  "v0.6.5",
+	OS:          []string{"linux", "darwin"},
+	Arch:        []string{"amd64", "arm64"},
+	After:       []string{"ohmyzsh"}, // ohmyzsh 会重新生成 ~/.zshrc, 需要先于vmr安装
+	Suffixes:    []string{".zip"},
+	Assets: map[string]string{
+		"linux/amd64":  "vmr_linux-amd64.zip",
+		"linux/arm64":  "vmr_linux-arm64.zip",
+		"darwin/amd64": "vmr_darwin-amd64.zip",
+		"darwin/arm64": "vmr_darwin-arm64.zip",
+	},
+	InstallDir: "~/.vmr",
+	VersionCmd: "~/.vmr/vmr version",
 }
 
-func (vm *VMRManager) Installing(flags *GlobalFlags) error {
-	srcFileName := fmt.Sprintf("vmr_%s-%s.zip", vm.config.OS, vm.config.ARCH)
-
-	// 获取最新的 GitHub 版本信息
-	githubInfo := utils.NewGithubRepoInfo(
-		vm.ower, vm.repo,
-		flags.HttpProxy,
-		flags.GithubProxy,
-		vm.config.Logger,
-	)
-	var tagName string
-	if flags.Tag == "" {
-		tagName = githubInfo.GetLatestReleaseTag()
-		if tagName == "" {
-			tagName = vm.tagName
-		}
-	} else {
-		tagName = flags.Tag
-	}
-
-	if err := utils.Mkdir(vm.vmrDir, vm.config.Logger); err != nil {
-		return err
-	}
-
-	downloadFile := fmt.Sprintf("%s/%s", vm.vmrDir, srcFileName)
-
-	if err := utils.RemoveFile(downloadFile, vm.config.Logger); err != nil {
-		return err
-	}
-
-	if err := githubInfo.DownloadReleaseLatestFile(downloadFile, srcFileName, tagName); err != nil {
-		return err
-	}
-
-	// 使用 archiver 解压 ZIP 文件
-	vmrPath := fmt.Sprintf("%s/vmr", vm.vmrDir)
-	if err := utils.RemoveFile(vmrPath, vm.config.Logger); err != nil {
-		return err
-	}
-	if err := archiver.Unarchive(downloadFile, vm.vmrDir); err != nil {
-		vm.config.Logger.Errorf("解压VMR文件%s失败:%s", downloadFile, err)
-		return err
-	}
-	vm.config.Logger.Infof("已解压VMR文件:%s", downloadFile)
+// Define VMRManager to handleVMRoperations
+type VMRManager struct {
+	*ReleaseBinaryManager
+	Name   string
+	config *config.Config
+	vmrDir string
+}
 
-	// 删除下载的VMR压缩文件
-	if err := utils.RemoveFile(downloadFile, vm.config.Logger); err != nil {
-		return nil
+func NewVMRManager(config *config.Config) *VMRManager {
+	vm := &VMRManager{
+		ReleaseBinaryManager: NewReleaseBinaryManager(vmrBinary, config),
+		Name:                 vmrBinary.Name,
+		config:               config,
+		vmrDir:               fmt.Sprintf("%s/.vmr", config.HomeDir),
 	}
+	vm.postInstall = vm.configure
+	return vm
+}
 
+// configure 在安装可执行文件后生成VMR的配置和启动脚本, 并在shell配置文件中加载
+func (vm *VMRManager) configure(flags *GlobalFlags, tx *utils.Transaction, rec *state.Record) error {
 	confPath := fmt.Sprintf("%s/conf.toml", vm.vmrDir)
 	vm.config.Logger.Infof("生成VMR配置:%s", confPath)
 	vmrConf := fmt.Sprintf(`
@@ -95,7 +65,10 @@ ThreadNum = 0
 UseCustomedMirrors = true
 `, vm.vmrDir)
 
-	if err := os.WriteFile(confPath, []byte(vmrConf), 0o644); err != nil {
+	if err := tx.Backup(confPath, false); err != nil {
+		return err
+	}
+	if err := utils.WriteFile(confPath, []byte(vmrConf), 0o644, vm.config.Logger); err != nil {
 		vm.config.Logger.Errorf("生成VMR配置文件%s失败:%s", confPath, err)
 		return err
 	}
@@ -107,7 +80,10 @@ UseCustomedMirrors = true
 'https://nodejs.org/download/release/' = 'https://mirrors.ustc.edu.cn/node/'
 'https://repo.anaconda.com/miniconda/' = 'https://mirrors.ustc.edu.cn/anaconda/miniconda/'
 `
-	if err := os.WriteFile(mirrorsPath, []byte(customedMirrors), 0o644); err != nil {
+	if err := tx.Backup(mirrorsPath, false); err != nil {
+		return err
+	}
+	if err := utils.WriteFile(mirrorsPath, []byte(customedMirrors), 0o644, vm.config.Logger); err != nil {
 		vm.config.Logger.Errorf("生成VMR镜像配置文件%s失败:%s", mirrorsPath, err)
 		return err
 	}
@@ -135,7 +111,10 @@ if [ ! $VMR_CD_INIT ]; then
 fi
 # cd hook end
 `, vm.vmrDir)
-	if err := os.WriteFile(scriptPath, []byte(vmrScript), 0o755); err != nil {
+	if err := tx.Backup(scriptPath, false); err != nil {
+		return err
+	}
+	if err := utils.WriteFile(scriptPath, []byte(vmrScript), 0o755, vm.config.Logger); err != nil {
 		vm.config.Logger.Errorf("生成VMR启动脚本%s失败:%s", scriptPath, err)
 		return err
 	}
@@ -152,61 +131,38 @@ if [ -z "$VM_DISABLE" ]; then
 fi
 # vm_envs end
 `
+	rec.Files = append(rec.Files, newFileRecords(confPath, mirrorsPath, scriptPath)...)
 	for _, shellFile := range shellFiles {
 		if !utils.FileExists(shellFile) {
+			vm.config.Logger.Warnf("配置文件%s不存在,跳过添加VMR配置", shellFile)
 			continue
 		}
-		if err := utils.UpdateConfigFiles(shellFile, contentToAdd); err != nil {
+		if err := tx.Backup(shellFile, false); err != nil {
+			return err
+		}
+		if err := utils.UpdateConfigFiles(shellFile, contentToAdd, vm.config.Logger); err != nil {
 			vm.config.Logger.Errorf("文件%s添加配置失败：%s", shellFile, err)
 		} else {
 			vm.config.Logger.Infof("配置文件%s更新如下配置:", shellFile)
 			vm.config.Logger.Infof(contentToAdd)
+			rec.RcBlocks = append(rec.RcBlocks, state.RcBlock{
+				File:  shellFile,
+				Start: "# vm_envs start",
+				End:   "# vm_envs end",
+			})
 		}
 	}
 	return nil
 }
 
-func (vm *VMRManager) GetName() string {
-	return vm.Name
-}
-
-func (vm *VMRManager) Install(flags *GlobalFlags) error {
-	if !flags.Force && vm.isInstalled() {
-		vm.config.Logger.Warn("VMR已经安装。使用 -f 选项强制重新安装。")
-		return nil
-	}
-
-	vm.config.Logger.Infof("开始安装VMR...")
-	if err := vm.Installing(flags); err != nil {
-		os.Exit(1)
-	}
-	vm.config.Logger.Infof("VMR安装成功!")
-	return nil
-}
-
-func (vm *VMRManager) Update(flags *GlobalFlags) error {
-	if !vm.isInstalled() {
-		vm.config.Logger.Warn("VMR尚未安装。请使用 'install' 命令首先安装它。")
-		return nil
-	}
-
-	// Add update logic here
-	vm.config.Logger.Info("更新VMR...")
-	if err := vm.Installing(flags); err != nil {
-		os.Exit(1)
-	}
-	vm.config.Logger.Infof("VMR更新成功!")
-	return nil
-}
-
 func (vm *VMRManager) Delete(flags *GlobalFlags) error {
 	// Add deletion logic here
 	vm.config.Logger.Info("开始删除VMR...")
 
 	// 删除VMR目录及其内容
-	if err := os.RemoveAll(vm.vmrDir); err != nil {
+	if err := utils.RemoveFile(vm.vmrDir, vm.config.Logger); err != nil {
 		vm.config.Logger.Errorf("删除VMR目录%s失败:%s", vm.vmrDir, err)
-		os.Exit(1)
+		return err
 	}
 	vm.config.Logger.Infof("已删除VMR目录:%s", vm.vmrDir)
 
@@ -217,19 +173,15 @@ func (vm *VMRManager) Delete(flags *GlobalFlags) error {
 	}
 	contentPattern := `# vm_envs start\nif \[ -z "\$VM_DISABLE" \]; then\n    \. ~/.vmr/vmr.sh\nfi\n# vm_envs end\n`
 	for _, shellFile := range shellFiles {
-		if err := utils.RemoveConfigFromFile(shellFile, contentPattern); err != nil {
+		if err := utils.RemoveConfigFromFile(shellFile, contentPattern, vm.config.Logger); err != nil {
 			vm.config.Logger.Errorf("从文件%s移除配置失败:%s", shellFile, err)
-			os.Exit(1)
-		} else {
-			vm.config.Logger.Infof("从文件%s移除配置成功", shellFile)
+			return err
 		}
+		vm.config.Logger.Infof("从文件%s移除配置成功", shellFile)
 	}
 
 	vm.config.Logger.Infof("已从配置文件中移除VMR配置")
+	removeState(vm.Name)
 	vm.config.Logger.Infof("VMR删除成功!")
 	return nil
 }
-
-func (vm *VMRManager) isInstalled() bool {
-	return utils.IsCommandAvailable("vmr")
-}
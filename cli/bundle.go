@@ -0,0 +1,40 @@
+package cli
+
+import (
+	cli "github.com/urfave/cli/v2"
+
+	"github.com/bookandmusic/envsetup/app"
+	"github.com/bookandmusic/envsetup/config"
+)
+
+// bundleCommand creates the command building offline bundles for air-gapped machines
+func bundleCommand(apps []app.Manager) *cli.Command {
+	return &cli.Command{
+		Name:     "bundle",
+		Usage:    "制作离线安装包",
+		HideHelp: true,
+		Flags:    commonFlags,
+		Subcommands: []*cli.Command{
+			{
+				Name:     "create",
+				Usage:    "下载应用所需的Release文件和Git仓库, 打包为离线安装包",
+				HideHelp: true,
+				Flags:    bundleFlags,
+				Action: func(c *cli.Context) error {
+					cfg := config.GetConfig()
+					osType, arch := c.String("os"), c.String("arch")
+					if osType == "" {
+						osType = cfg.OS
+					}
+					if arch == "" {
+						arch = cfg.ARCH
+					}
+					return app.CreateBundle(apps, c.StringSlice("apps"), osType, arch, c.String("output"), &app.GlobalFlags{
+						HttpProxy:   c.String("https-proxy"),
+						GithubProxy: c.String("github-proxy"),
+					})
+				},
+			},
+		},
+	}
+}
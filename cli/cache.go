@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	cli "github.com/urfave/cli/v2"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// cacheCommand creates the command managing the release download cache
+func cacheCommand() *cli.Command {
+	newCache := func() *utils.DownloadCache {
+		cfg := config.GetConfig()
+		return utils.NewDownloadCache(cfg.CacheDir, cfg.Logger)
+	}
+
+	return &cli.Command{
+		Name:     "cache",
+		Usage:    "管理Release下载缓存",
+		HideHelp: true,
+		Flags:    commonFlags,
+		Subcommands: []*cli.Command{
+			{
+				Name:     "list",
+				Usage:    "显示已缓存的文件",
+				Aliases:  []string{"ls"},
+				HideHelp: true,
+				Flags:    commonFlags,
+				Action: func(c *cli.Context) error {
+					cache := newCache()
+					entries, err := cache.List()
+					if err != nil {
+						return err
+					}
+					cfg := utils.TableConfig{
+						Header: table.Row{"仓库", "版本", "文件", "大小", "缓存时间", "SHA256"},
+					}
+					var total int64
+					for _, entry := range entries {
+						total += entry.Size
+						cfg.Data = append(cfg.Data, table.Row{
+							entry.Owner + "/" + entry.Repo, entry.Tag, entry.Asset, formatSize(entry.Size),
+							entry.CachedAt.Format("2006-01-02 15:04:05"), entry.SHA256[:12],
+						})
+					}
+					utils.RenderTable(&cfg, os.Stdout)
+					fmt.Printf("缓存目录: %s, 共%d个文件, %s\n", cache.Dir(), len(entries), formatSize(total))
+					return nil
+				},
+			},
+			{
+				Name:     "prune",
+				Usage:    "删除过期的缓存和未完成的下载",
+				HideHelp: true,
+				Flags:    []cli.Flag{helpFlag, maxAgeFlag},
+				Action: func(c *cli.Context) error {
+					freed, err := newCache().Prune(c.Duration("max-age"))
+					if err != nil {
+						return err
+					}
+					config.GetConfig().Logger.Infof("清理缓存完成, 释放%s", formatSize(freed))
+					return nil
+				},
+			},
+			{
+				Name:     "clear",
+				Usage:    "删除全部缓存",
+				HideHelp: true,
+				Flags:    commonFlags,
+				Action: func(c *cli.Context) error {
+					freed, err := newCache().Clear()
+					if err != nil {
+						return err
+					}
+					config.GetConfig().Logger.Infof("已删除全部缓存, 释放%s", formatSize(freed))
+					return nil
+				},
+			},
+		},
+	}
+}
+
+// formatSize formats a byte count for display
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
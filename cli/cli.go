@@ -1,8 +1,13 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	cli "github.com/urfave/cli/v2"
@@ -14,28 +19,51 @@ import (
 
 var (
 	commonFlags  = []cli.Flag{helpFlag}
-	installFlags = []cli.Flag{helpFlag, tagFlag, forceFlag, httpsProxyFlag, githubProxyFlag}
-	updateFlags  = []cli.Flag{helpFlag, httpsProxyFlag, githubProxyFlag}
+	appFlags     = []cli.Flag{helpFlag, dryRunFlag, nonInteractiveFlag, noCacheFlag, githubTokenFlag}
+	installFlags = []cli.Flag{helpFlag, tagFlag, forceFlag, httpsProxyFlag, githubProxyFlag, jobsFlag, skipVerifyFlag}
+	updateFlags  = []cli.Flag{helpFlag, httpsProxyFlag, githubProxyFlag, jobsFlag, skipVerifyFlag}
 	deleteFlags  = []cli.Flag{helpFlag}
+	applyFlags   = []cli.Flag{helpFlag, fileFlag, jobsFlag}
+	statusFlags  = []cli.Flag{helpFlag, jsonFlag, httpsProxyFlag, githubProxyFlag}
+	doctorFlags  = []cli.Flag{helpFlag, jsonFlag, httpsProxyFlag, githubProxyFlag}
+	bundleFlags  = []cli.Flag{helpFlag, appsFlag, outputFlag, osFlag, archFlag, httpsProxyFlag, githubProxyFlag}
 )
 
-// generateSubcommands creates subcommands for a given action
-func generateSubcommands(action func(app.Manager, *app.GlobalFlags) error, apps []app.Manager, actionName string, flags []cli.Flag) []*cli.Command {
+// runMode controls how the apps named on the command line are ordered
+type runMode int
+
+const (
+	// runInOrder runs the named apps in dependency order
+	runInOrder runMode = iota
+	// runWithDependencies also runs missing dependencies of the named apps
+	runWithDependencies
+	// runInReverseOrder runs dependents before their dependencies
+	runInReverseOrder
+)
+
+// generateSubcommands creates subcommands for a given action. Extra arguments
+// name more apps, which are run together in dependency order.
+func generateSubcommands(action func(app.Manager, *app.GlobalFlags) error, apps []app.Manager, actionName string, flags []cli.Flag, mode runMode) []*cli.Command {
 	var commands []*cli.Command
 
 	for _, mgr := range apps {
-		mgr := mgr // capture the loop variable
+		meta := mgr.GetMetadata()
 		commands = append(commands, &cli.Command{
-			Name:     mgr.GetName(),
-			Usage:    fmt.Sprintf("%s%s", actionName, mgr.GetName()),
-			Flags:    flags,
-			HideHelp: true,
+			Name:        meta.Name,
+			Usage:       fmt.Sprintf("%s%s: %s", actionName, meta.Name, meta.Description),
+			Description: describeMetadata(meta),
+			ArgsUsage:   "[其他应用...]",
+			Flags:       flags,
+			HideHelp:    true,
 			Action: func(c *cli.Context) error {
-				return action(mgr, &app.GlobalFlags{
+				names := append([]string{meta.Name}, c.Args().Slice()...)
+				return runOrdered(action, apps, names, mode, &app.GlobalFlags{
 					Force:       c.Bool("force"),
 					Tag:         c.String("tag"),
 					HttpProxy:   c.String("https-proxy"),
 					GithubProxy: c.String("github-proxy"),
+					Jobs:        c.Int("jobs"),
+					SkipVerify:  c.Bool("skip-verify"),
 				})
 			},
 		})
@@ -43,17 +71,90 @@ func generateSubcommands(action func(app.Manager, *app.GlobalFlags) error, apps
 	return commands
 }
 
+// runOrdered resolves the execution order of the named apps and runs action
+// on each of them, running up to flags.Jobs independent apps at a time
+func runOrdered(action func(app.Manager, *app.GlobalFlags) error, apps []app.Manager, names []string, mode runMode, flags *app.GlobalFlags) error {
+	ordered, err := app.ResolveOrder(apps, names)
+	if err != nil {
+		return err
+	}
+
+	requested := make(map[string]bool, len(names))
+	for _, name := range names {
+		requested[name] = true
+	}
+	if mode == runInReverseOrder {
+		ordered = app.Reverse(ordered)
+	}
+
+	var selected []app.Manager
+	for _, mgr := range ordered {
+		if mode == runWithDependencies || requested[mgr.GetName()] {
+			selected = append(selected, mgr)
+		}
+	}
+
+	logger := config.GetConfig().Logger
+	if len(selected) > 1 {
+		var order []string
+		for _, mgr := range selected {
+			order = append(order, mgr.GetName())
+		}
+		logger.Infof("执行顺序: %s", strings.Join(order, " -> "))
+	}
+
+	if mode == runWithDependencies {
+		// 安装前一次性安装所有应用需要的系统软件包
+		stop, err := app.PrepareSystemPackages(selected)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	return app.RunParallel(selected, flags.Jobs, mode == runInReverseOrder, func(mgr app.Manager) error {
+		if !requested[mgr.GetName()] && mgr.IsInstalled() {
+			logger.Infof("依赖%s已安装,跳过", mgr.GetName())
+			return nil
+		}
+		return action(mgr, flags)
+	})
+}
+
+// describeMetadata generates the help description of an app from its metadata
+func describeMetadata(meta *app.Metadata) string {
+	lines := []string{meta.Description, fmt.Sprintf("主页: %s", meta.Homepage)}
+	if meta.DefaultTag != "" {
+		lines = append(lines, fmt.Sprintf("默认版本: %s", meta.DefaultTag))
+	}
+	if len(meta.OS) > 0 || len(meta.Arch) > 0 {
+		lines = append(lines, fmt.Sprintf("支持平台: %s / %s", joinOrAny(meta.OS), joinOrAny(meta.Arch)))
+	}
+	if len(meta.Dependencies) > 0 {
+		lines = append(lines, fmt.Sprintf("依赖: %s", strings.Join(meta.Dependencies, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinOrAny(list []string) string {
+	if len(list) == 0 {
+		return "any"
+	}
+	return strings.Join(list, ",")
+}
+
 // CreateApp initializes the CLI app with commands
 func CreateApp() *cli.App {
 	// Initialize global configuration
 	config.InitConfig()
+	// 用户自定义的应用和PATH中的插件与内置应用一起出现在各个命令中
+	cfg := config.GetConfig()
+	app.LoadManifests(filepath.Join(cfg.ConfigDir, "managers.d"), cfg)
+	app.LoadPlugins(os.Getenv("PATH"), cfg)
 
-	apps := []app.Manager{
-		app.NewChsrcManager(),
-		app.NewVMRManager(),
-		app.NewVimrcManager(),
-		app.NewOhMyZshManager(),
-	}
+	apps := app.Managers()
+	// 离线安装时用于清理解压的安装包
+	var closeBundle func()
 
 	commands := []*cli.Command{
 		{
@@ -64,13 +165,13 @@ func CreateApp() *cli.App {
 			Flags:    commonFlags,
 			Action: func(c *cli.Context) error {
 				cfg := utils.TableConfig{
-					Header: table.Row{"名称", "描述"},
-					Data: []table.Row{
-						{"vimrc", "Vim编辑器的配置文件,用于定制编辑器的行为和外观"},
-						{"ohmyzsh", "一个增强Zsh配置的开源框架,提供丰富的插件、主题和配置选项"},
-						{"vmr", "一个简单、跨平台的版本管理器,用于管理多种 SDK 及其他工具"},
-						{"chsrc", "一个全平台的命令行换源工具"},
-					},
+					Header: table.Row{"名称", "描述", "主页", "默认版本", "依赖"},
+				}
+				for _, mgr := range apps {
+					meta := mgr.GetMetadata()
+					cfg.Data = append(cfg.Data, table.Row{
+						meta.Name, meta.Description, meta.Homepage, meta.DefaultTag, strings.Join(meta.Dependencies, ", "),
+					})
 				}
 				utils.RenderTable(&cfg, os.Stdout)
 				return nil
@@ -81,12 +182,27 @@ func CreateApp() *cli.App {
 			Usage:    "安装应用程序",
 			Aliases:  []string{"i"},
 			HideHelp: true,
-			Flags:    commonFlags,
+			Flags:    []cli.Flag{helpFlag, fromBundleFlag},
+			Before: func(c *cli.Context) error {
+				if c.String("from-bundle") == "" {
+					return nil
+				}
+				var err error
+				closeBundle, err = app.UseBundle(c.String("from-bundle"))
+				return err
+			},
+			After: func(c *cli.Context) error {
+				if closeBundle != nil {
+					closeBundle()
+				}
+				return nil
+			},
 			Subcommands: generateSubcommands(
 				func(mgr app.Manager, flags *app.GlobalFlags) error { return mgr.Install(flags) },
 				apps,
 				"安装",
 				installFlags,
+				runWithDependencies,
 			),
 		},
 		{
@@ -100,6 +216,7 @@ func CreateApp() *cli.App {
 				apps,
 				"更新",
 				updateFlags,
+				runInOrder,
 			),
 		},
 		{
@@ -113,15 +230,194 @@ func CreateApp() *cli.App {
 				apps,
 				"删除",
 				deleteFlags,
+				runInReverseOrder,
 			),
 		},
+		{
+			Name:     "status",
+			Usage:    "显示应用程序的安装状态、版本及本地文件变化",
+			Aliases:  []string{"st"},
+			HideHelp: true,
+			Flags:    statusFlags,
+			Action: func(c *cli.Context) error {
+				flags := &app.GlobalFlags{
+					HttpProxy:   c.String("https-proxy"),
+					GithubProxy: c.String("github-proxy"),
+				}
+				var statuses []*app.AppStatus
+				for _, mgr := range apps {
+					statuses = append(statuses, app.GetStatus(mgr, flags))
+				}
+				if c.Bool("json") {
+					return renderJSON(statuses, os.Stdout)
+				}
+				return renderStatusTable(statuses, os.Stdout)
+			},
+		},
+		{
+			Name:     "doctor",
+			Usage:    "检查安装应用程序所需的系统环境",
+			HideHelp: true,
+			Flags:    doctorFlags,
+			Action: func(c *cli.Context) error {
+				results := app.RunDoctor(&app.GlobalFlags{
+					HttpProxy:   c.String("https-proxy"),
+					GithubProxy: c.String("github-proxy"),
+				})
+				var err error
+				if c.Bool("json") {
+					err = renderJSON(results, os.Stdout)
+				} else {
+					err = renderDoctorTable(results, os.Stdout)
+				}
+				if err != nil {
+					return err
+				}
+
+				failed := 0
+				for _, result := range results {
+					if result.Status == app.CheckFail {
+						failed++
+					}
+				}
+				if failed > 0 {
+					return cli.Exit(fmt.Sprintf("环境检查发现%d项失败", failed), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "apply",
+			Usage:    "根据环境配置文件安装、更新或删除应用程序",
+			HideHelp: true,
+			Flags:    applyFlags,
+			Action: func(c *cli.Context) error {
+				profile, err := config.LoadProfile(c.String("file"))
+				if err != nil {
+					return err
+				}
+				return app.ApplyProfile(profile, apps, c.Int("jobs"))
+			},
+		},
+		cacheCommand(),
+		bundleCommand(apps),
+		mirrorCommand(),
 	}
 
 	return &cli.App{
 		Name:     "envsetup",
 		Usage:    "配置基本开发环境",
 		HideHelp: true,
-		Flags:    commonFlags,
+		Flags:    appFlags,
 		Commands: commands,
+		Before: func(c *cli.Context) error {
+			cfg := config.GetConfig()
+			utils.SetDryRun(c.Bool("dry-run"))
+			utils.SetNonInteractive(c.Bool("non-interactive"))
+			utils.SetGithubToken(c.String("github-token"))
+			network := cfg.Settings.Network
+			utils.SetRetryPolicy(utils.RetryPolicy{Retries: network.GetRetries(), Delay: network.GetRetryDelay()})
+			utils.SetGithubMirrors(network.Mirrors)
+			probe := utils.MirrorProbeOptions{
+				Mirrors:   network.Mirrors,
+				URL:       network.ProbeURL,
+				Timeout:   10 * time.Second,
+				CacheFile: filepath.Join(cfg.CacheDir, "mirrors.json"),
+				TTL:       network.GetProbeTTL(),
+			}
+			if len(probe.Mirrors) == 0 {
+				probe.Mirrors = utils.DefaultGithubMirrors
+			}
+			if probe.URL == "" {
+				probe.URL = utils.DefaultProbeURL
+			}
+			utils.SetMirrorProbeOptions(probe)
+			sources, err := repoSources(cfg.Settings)
+			if err != nil {
+				return err
+			}
+			utils.SetRepoSources(sources)
+			utils.SetPackageNames(cfg.Settings.Packages)
+			if !c.Bool("no-cache") {
+				utils.SetDownloadCache(utils.NewDownloadCache(cfg.CacheDir, cfg.Logger))
+			}
+			return nil
+		},
+		After: func(c *cli.Context) error {
+			if !utils.IsDryRun() {
+				return nil
+			}
+			fmt.Println("dry-run 模式, 以下操作均未执行:")
+			return utils.RenderPlan(os.Stdout)
+		},
+	}
+}
+
+// repoSources 根据配置文件的repos生成改为从其他代码平台获取的仓库
+func repoSources(settings *config.Settings) (map[string]utils.RepoSource, error) {
+	sources := make(map[string]utils.RepoSource, len(settings.Repos))
+	for name, rs := range settings.Repos {
+		ss, _ := settings.GetSource(rs.Source)
+		source, err := utils.NewSource(ss.Type, ss.URL, ss.Token)
+		if err != nil {
+			return nil, err
+		}
+		path := rs.Path
+		if path == "" {
+			path = name
+		}
+		i := strings.LastIndex(path, "/")
+		sources[name] = utils.RepoSource{Source: source, Owner: path[:i], Repo: path[i+1:]}
+	}
+	return sources, nil
+}
+
+// renderJSON 以缩进的JSON格式输出
+func renderJSON(v interface{}, w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// renderStatusTable 以表格形式输出应用状态
+func renderStatusTable(statuses []*app.AppStatus, w io.Writer) error {
+	cfg := utils.TableConfig{
+		Header: table.Row{"名称", "已安装", "当前版本", "最新版本", "本地变化"},
+	}
+	for _, st := range statuses {
+		installed := "否"
+		if st.Installed {
+			installed = "是"
+		}
+		latest := st.Latest
+		if latest == "" {
+			latest = "未知"
+		} else if st.Outdated {
+			latest += " (可更新)"
+		}
+		drift := "无"
+		if len(st.Drift) > 0 {
+			drift = strings.Join(st.Drift, "\n")
+		} else if st.Installed && !st.Managed {
+			drift = "无安装记录"
+		}
+		cfg.Data = append(cfg.Data, table.Row{st.Name, installed, st.Version, latest, drift})
+	}
+	return utils.RenderTable(&cfg, w)
+}
+
+// renderDoctorTable 以表格形式输出环境检查结果
+func renderDoctorTable(results []*app.CheckResult, w io.Writer) error {
+	labels := map[string]string{
+		app.CheckPass: "通过",
+		app.CheckWarn: "警告",
+		app.CheckFail: "失败",
+	}
+	cfg := utils.TableConfig{
+		Header: table.Row{"检查项", "结果", "说明", "建议"},
+	}
+	for _, result := range results {
+		cfg.Data = append(cfg.Data, table.Row{result.Name, labels[result.Status], result.Message, result.Suggestion})
 	}
+	return utils.RenderTable(&cfg, w)
 }
@@ -1,6 +1,8 @@
 package cli
 
 import (
+	"time"
+
 	cli "github.com/urfave/cli/v2"
 )
 
@@ -29,6 +31,73 @@ var (
 	githubProxyFlag = &cli.StringFlag{
 		Name:    "github-proxy",
 		Aliases: []string{"gp"},
-		Usage:   "为GitHub请求启用代理。示例: --github-proxy=https://mirror.ghproxy.com/",
+		Usage:   "为GitHub请求启用代理, auto 表示自动选择最快的镜像。示例: --github-proxy=https://ghproxy.net/",
+	}
+	fileFlag = &cli.StringFlag{
+		Name:     "file",
+		Aliases:  []string{"f"},
+		Usage:    "指定环境配置文件。示例: --file=envsetup.yaml",
+		Required: true,
+	}
+	dryRunFlag = &cli.BoolFlag{
+		Name:    "dry-run",
+		Aliases: []string{"n"},
+		Usage:   "只打印将要执行的操作,不做任何修改",
+	}
+	nonInteractiveFlag = &cli.BoolFlag{
+		Name:    "non-interactive",
+		Usage:   "不询问任何输入, 提权需要密码时直接失败, 适用于CI",
+		EnvVars: []string{"ENVSETUP_NON_INTERACTIVE"},
+	}
+	jsonFlag = &cli.BoolFlag{
+		Name:  "json",
+		Usage: "以JSON格式输出",
+	}
+	jobsFlag = &cli.IntFlag{
+		Name:    "jobs",
+		Aliases: []string{"j"},
+		Usage:   "同时执行的下载、克隆和安装任务数",
+		Value:   1,
+	}
+	githubTokenFlag = &cli.StringFlag{
+		Name:    "github-token",
+		Usage:   "访问GitHub API使用的令牌, 可将请求上限从每小时60次提高到5000次",
+		EnvVars: []string{"GITHUB_TOKEN"},
+	}
+	noCacheFlag = &cli.BoolFlag{
+		Name:  "no-cache",
+		Usage: "不使用Release下载缓存",
+	}
+	maxAgeFlag = &cli.DurationFlag{
+		Name:  "max-age",
+		Usage: "保留最近多长时间内缓存的文件。示例: --max-age=168h",
+		Value: 30 * 24 * time.Hour,
+	}
+	appsFlag = &cli.StringSliceFlag{
+		Name:     "apps",
+		Usage:    "指定应用, 多个应用用逗号分隔。示例: --apps=vmr,chsrc",
+		Required: true,
+	}
+	outputFlag = &cli.StringFlag{
+		Name:     "output",
+		Aliases:  []string{"o"},
+		Usage:    "指定输出文件。示例: -o bundle.tar.gz",
+		Required: true,
+	}
+	osFlag = &cli.StringFlag{
+		Name:  "os",
+		Usage: "目标操作系统(linux, darwin)。默认为当前系统",
+	}
+	archFlag = &cli.StringFlag{
+		Name:  "arch",
+		Usage: "目标架构(amd64, arm64)。默认为当前架构",
+	}
+	fromBundleFlag = &cli.StringFlag{
+		Name:  "from-bundle",
+		Usage: "从离线安装包安装, 不访问网络。示例: --from-bundle=bundle.tar.gz",
+	}
+	skipVerifyFlag = &cli.BoolFlag{
+		Name:  "skip-verify",
+		Usage: "跳过下载文件的SHA256校验(不安全)",
 	}
 )
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	cli "github.com/urfave/cli/v2"
+
+	"github.com/bookandmusic/envsetup/config"
+	"github.com/bookandmusic/envsetup/utils"
+)
+
+// mirrorCommand creates the command measuring GitHub mirrors
+func mirrorCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "mirror",
+		Usage:    "GitHub加速镜像",
+		HideHelp: true,
+		Flags:    commonFlags,
+		Subcommands: []*cli.Command{
+			{
+				Name:     "probe",
+				Usage:    "测试直连及各个GitHub镜像的速度, 结果供 --github-proxy=auto 使用",
+				HideHelp: true,
+				Flags:    []cli.Flag{helpFlag, jsonFlag, httpsProxyFlag},
+				Action: func(c *cli.Context) error {
+					results := utils.ProbeMirrors(c.String("https-proxy"), config.GetConfig().Logger)
+					if c.Bool("json") {
+						return renderJSON(results, os.Stdout)
+					}
+
+					cfg := utils.TableConfig{
+						Header: table.Row{"镜像", "延迟", "速度", "状态"},
+					}
+					for _, result := range results {
+						if result.Error != "" {
+							cfg.Data = append(cfg.Data, table.Row{result.Name(), "-", "-", result.Error})
+							continue
+						}
+						cfg.Data = append(cfg.Data, table.Row{
+							result.Name(), result.Latency.Round(time.Millisecond),
+							formatSize(int64(result.Speed)) + "/s", "正常",
+						})
+					}
+					utils.RenderTable(&cfg, os.Stdout)
+					if len(results) > 0 && results[0].Error == "" {
+						fmt.Printf("最快的下载源: %s\n", results[0].Name())
+					}
+					return nil
+				},
+			},
+		},
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 
@@ -15,6 +16,14 @@ type Config struct {
 	OS      string
 	HomeDir string
 	IsRoot  bool
+	// envsetup 自身的数据目录, 默认为 ~/.envsetup
+	DataDir string
+	// 下载缓存目录, 默认为 ~/.cache/envsetup, 设置了 XDG_CACHE_HOME 时使用 $XDG_CACHE_HOME/envsetup
+	CacheDir string
+	// 用户配置目录, 默认为 ~/.config/envsetup, 设置了 XDG_CONFIG_HOME 时使用 $XDG_CONFIG_HOME/envsetup
+	ConfigDir string
+	// 用户配置文件 ConfigDir/config.yaml 的内容
+	Settings *Settings
 }
 
 var (
@@ -42,14 +51,32 @@ func InitConfig() {
 			os.Exit(1)
 		}
 		isRoot := os.Geteuid() == 0
+		dataDir := filepath.Join(homeDir, ".envsetup")
+		cacheDir := filepath.Join(homeDir, ".cache", "envsetup")
+		if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+			cacheDir = filepath.Join(xdg, "envsetup")
+		}
+		configDir := filepath.Join(homeDir, ".config", "envsetup")
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			configDir = filepath.Join(xdg, "envsetup")
+		}
+		settings, err := LoadSettings(filepath.Join(configDir, "config.yaml"))
+		if err != nil {
+			logger.Errorf("加载配置失败: %s", err)
+			os.Exit(1)
+		}
 
 		// 初始化全局配置对象
 		cfg = &Config{
-			Logger:  logger,
-			ARCH:    arch,
-			OS:      osType,
-			HomeDir: homeDir,
-			IsRoot:  isRoot,
+			Logger:    logger,
+			ARCH:      arch,
+			OS:        osType,
+			HomeDir:   homeDir,
+			IsRoot:    isRoot,
+			DataDir:   dataDir,
+			CacheDir:  cacheDir,
+			ConfigDir: configDir,
+			Settings:  settings,
 		}
 	})
 }
@@ -58,3 +85,10 @@ func InitConfig() {
 func GetConfig() *Config {
 	return cfg
 }
+
+// WithLogger 返回使用指定日志对象的配置副本, 用于并发任务分别收集日志
+func (c *Config) WithLogger(logger *logrus.Logger) *Config {
+	clone := *c
+	clone.Logger = logger
+	return &clone
+}
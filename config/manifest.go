@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 应用定义文件中的应用类型
+const (
+	ManifestRelease = "release" // 从Release下载可执行文件
+	ManifestRepo    = "repo"    // 克隆Git仓库
+	ManifestPackage = "package" // 使用系统的包管理器安装软件包
+	ManifestScript  = "script"  // 执行shell命令
+)
+
+var manifestTypes = []string{ManifestRelease, ManifestRepo, ManifestPackage, ManifestScript}
+
+// 应用名称会作为子命令使用
+var manifestNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Manifest 是 managers.d 目录中一个应用定义文件的内容
+type Manifest struct {
+	Name         string   `yaml:"name"`
+	Type         string   `yaml:"type"`
+	Description  string   `yaml:"description"`
+	Homepage     string   `yaml:"homepage"`
+	OS           []string `yaml:"os"`
+	Arch         []string `yaml:"arch"`
+	Dependencies []string `yaml:"dependencies"`
+	After        []string `yaml:"after"`
+
+	// release 和 repo 类型: 仓库所在的代码平台, 为空时为GitHub
+	Source string `yaml:"source"`
+	Owner  string `yaml:"owner"`
+	Repo   string `yaml:"repo"`
+
+	// release 类型, 含义见 app.ReleaseBinary
+	Tag        string              `yaml:"tag"`
+	OSNames    map[string][]string `yaml:"os_names"`
+	ArchNames  map[string][]string `yaml:"arch_names"`
+	Suffixes   []string            `yaml:"suffixes"`
+	Exclude    []string            `yaml:"exclude"`
+	Assets     map[string]string   `yaml:"assets"`
+	Format     string              `yaml:"format"`
+	Path       string              `yaml:"path"`
+	Bin        string              `yaml:"bin"`
+	InstallDir string              `yaml:"install_dir"`
+	Checksums  map[string]string   `yaml:"checksums"`
+
+	// repo 类型: 克隆到的目录, ~ 表示用户主目录
+	Dir string `yaml:"dir"`
+
+	// package 类型: 要安装的软件包
+	Packages []string `yaml:"packages"`
+
+	// script 类型: 安装、更新和删除时执行的命令, update 为空时执行 install
+	Install   string `yaml:"install"`
+	Update    string `yaml:"update"`
+	Uninstall string `yaml:"uninstall"`
+
+	// package 和 script 类型: 退出码为0表示已安装的命令
+	Check string `yaml:"check"`
+	// 查询已安装版本的命令
+	VersionCmd string `yaml:"version_cmd"`
+
+	// 定义所在的文件, 用于提示
+	File string `yaml:"-"`
+}
+
+// LoadManifests 读取dir中所有的 *.yaml 应用定义, 目录不存在时返回空。
+// 无效的定义放在errs中返回, 不影响其他定义的加载
+func LoadManifests(dir string, settings *Settings) ([]*Manifest, []error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, []error{err}
+	}
+	sort.Strings(files)
+
+	var manifests []*Manifest
+	var errs []error
+	seen := make(map[string]string)
+	for _, file := range files {
+		manifest, err := loadManifest(file, settings)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if other, ok := seen[manifest.Name]; ok {
+			errs = append(errs, fmt.Errorf("应用定义%s中的应用%s已在%s中定义", file, manifest.Name, other))
+			continue
+		}
+		seen[manifest.Name] = file
+		manifests = append(manifests, manifest)
+	}
+	return manifests, errs
+}
+
+func loadManifest(path string, settings *Settings) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取应用定义%s失败: %w", path, err)
+	}
+	manifest := &Manifest{File: path}
+	if err := yaml.Unmarshal(content, manifest); err != nil {
+		return nil, fmt.Errorf("解析应用定义%s失败: %w", path, err)
+	}
+	if err := manifest.validate(settings); err != nil {
+		return nil, fmt.Errorf("应用定义%s无效: %w", path, err)
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) validate(settings *Settings) error {
+	if !manifestNamePattern.MatchString(m.Name) {
+		return fmt.Errorf("name无效: %q, 只能包含小写字母、数字、.、_ 和 -", m.Name)
+	}
+	switch m.Type {
+	case ManifestRelease, ManifestRepo:
+		if m.Owner == "" || m.Repo == "" {
+			return fmt.Errorf("%s类型需要指定owner和repo", m.Type)
+		}
+		if m.Source != "" {
+			if _, ok := settings.GetSource(m.Source); !ok {
+				return fmt.Errorf("代码平台%s未定义", m.Source)
+			}
+		}
+		if m.Type == ManifestRepo && m.Dir == "" {
+			return fmt.Errorf("repo类型需要指定dir")
+		}
+	case ManifestPackage:
+		if len(m.Packages) == 0 {
+			return fmt.Errorf("package类型需要指定packages")
+		}
+	case ManifestScript:
+		if m.Install == "" || m.Check == "" {
+			return fmt.Errorf("script类型需要指定install和check")
+		}
+	default:
+		return fmt.Errorf("type无效: %q (可选值: %s)", m.Type, strings.Join(manifestTypes, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeManifests(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadManifests(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"a-tool.yaml": "name: tool\ntype: release\nowner: team\nrepo: tool\nsource: company\nassets:\n  linux/amd64: tool-{version}-linux-amd64.tar.gz\n",
+		"b-repo.yaml": "name: dotfiles\ntype: repo\nowner: team\nrepo: dotfiles\ndir: ~/.dotfiles\n",
+		"c-pkg.yaml":  "name: devtools\ntype: package\npackages: [jq, tmux]\n",
+		"d-sh.yaml":   "name: hello\ntype: script\ninstall: echo hi\ncheck: 'true'\n",
+		"ignored.yml": "name: yml\n",
+	})
+	settings := &Settings{Sources: map[string]SourceSettings{"company": {Type: "gitlab", URL: "https://git.example.com"}}}
+
+	manifests, errs := LoadManifests(dir, settings)
+	if len(errs) != 0 {
+		t.Fatalf("LoadManifests() errs = %v", errs)
+	}
+	var names []string
+	for _, m := range manifests {
+		names = append(names, m.Name)
+	}
+	if got := strings.Join(names, ","); got != "tool,dotfiles,devtools,hello" {
+		t.Errorf("LoadManifests() = %s", got)
+	}
+	if manifests[0].Assets["linux/amd64"] != "tool-{version}-linux-amd64.tar.gz" || manifests[0].File != filepath.Join(dir, "a-tool.yaml") {
+		t.Errorf("release定义 = %+v", manifests[0])
+	}
+	if len(manifests[2].Packages) != 2 {
+		t.Errorf("package定义 = %+v", manifests[2])
+	}
+}
+
+func TestLoadManifestsInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "缺少name", content: "type: script\ninstall: x\ncheck: x\n", wantErr: "name无效"},
+		{name: "name包含空格", content: "name: my tool\ntype: script\ninstall: x\ncheck: x\n", wantErr: "name无效"},
+		{name: "未知类型", content: "name: x\ntype: docker\n", wantErr: "type无效"},
+		{name: "release缺少仓库", content: "name: x\ntype: release\nowner: o\n", wantErr: "owner和repo"},
+		{name: "repo缺少目录", content: "name: x\ntype: repo\nowner: o\nrepo: r\n", wantErr: "dir"},
+		{name: "未定义的代码平台", content: "name: x\ntype: repo\nowner: o\nrepo: r\ndir: ~/r\nsource: nowhere\n", wantErr: "代码平台nowhere未定义"},
+		{name: "package缺少软件包", content: "name: x\ntype: package\n", wantErr: "packages"},
+		{name: "script缺少检查命令", content: "name: x\ntype: script\ninstall: x\n", wantErr: "install和check"},
+		{name: "YAML格式错误", content: "name: [x\n", wantErr: "解析应用定义"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeManifests(t, map[string]string{"bad.yaml": tt.content, "good.yaml": "name: good\ntype: package\npackages: [jq]\n"})
+			manifests, errs := LoadManifests(dir, &Settings{})
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Fatalf("LoadManifests() errs = %v, want %s", errs, tt.wantErr)
+			}
+			if len(manifests) != 1 || manifests[0].Name != "good" {
+				t.Errorf("无效的定义不应影响其他定义, got %v", manifests)
+			}
+		})
+	}
+}
+
+func TestLoadManifestsDuplicate(t *testing.T) {
+	dir := writeManifests(t, map[string]string{
+		"a.yaml": "name: x\ntype: package\npackages: [jq]\n",
+		"b.yaml": "name: x\ntype: package\npackages: [tmux]\n",
+	})
+	manifests, errs := LoadManifests(dir, &Settings{})
+	if len(manifests) != 1 || manifests[0].Packages[0] != "jq" || len(errs) != 1 {
+		t.Errorf("LoadManifests() = %v, %v", manifests, errs)
+	}
+}
+
+func TestLoadManifestsMissingDir(t *testing.T) {
+	manifests, errs := LoadManifests(filepath.Join(t.TempDir(), "managers.d"), &Settings{})
+	if len(manifests) != 0 || len(errs) != 0 {
+		t.Errorf("LoadManifests() = %v, %v", manifests, errs)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 应用在配置文件中的期望状态
+const (
+	StatePresent = "present" // 已安装即可
+	StateLatest  = "latest"  // 安装并更新到最新
+	StateAbsent  = "absent"  // 需要删除
+)
+
+// Profile 描述一份声明式的环境配置文件
+type Profile struct {
+	HttpsProxy  string       `yaml:"https_proxy"`
+	GithubProxy string       `yaml:"github_proxy"`
+	Apps        []ProfileApp `yaml:"apps"`
+}
+
+// ProfileApp 描述配置文件中单个应用的期望状态
+type ProfileApp struct {
+	Name        string            `yaml:"name"`
+	State       string            `yaml:"state"`
+	Tag         string            `yaml:"tag"`
+	Force       bool              `yaml:"force"`
+	SkipVerify  bool              `yaml:"skip_verify"`
+	HttpsProxy  string            `yaml:"https_proxy"`
+	GithubProxy string            `yaml:"github_proxy"`
+	Options     map[string]string `yaml:"options"`
+}
+
+// LoadProfile 读取并校验配置文件
+func LoadProfile(path string) (*Profile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件%s失败: %w", path, err)
+	}
+
+	profile := &Profile{}
+	if err := yaml.Unmarshal(content, profile); err != nil {
+		return nil, fmt.Errorf("解析配置文件%s失败: %w", path, err)
+	}
+
+	seen := make(map[string]bool)
+	for i := range profile.Apps {
+		app := &profile.Apps[i]
+		if app.Name == "" {
+			return nil, fmt.Errorf("配置文件%s第%d个应用缺少name", path, i+1)
+		}
+		if seen[app.Name] {
+			return nil, fmt.Errorf("配置文件%s中应用%s重复定义", path, app.Name)
+		}
+		seen[app.Name] = true
+
+		switch app.State {
+		case "":
+			app.State = StatePresent
+		case StatePresent, StateLatest, StateAbsent:
+		default:
+			return nil, fmt.Errorf("应用%s的state无效: %s (可选值: present, latest, absent)", app.Name, app.State)
+		}
+
+		// 应用未单独指定代理时, 使用配置文件的全局代理
+		if app.HttpsProxy == "" {
+			app.HttpsProxy = profile.HttpsProxy
+		}
+		if app.GithubProxy == "" {
+			app.GithubProxy = profile.GithubProxy
+		}
+	}
+	return profile, nil
+}
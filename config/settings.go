@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Settings 是用户配置文件(默认为 ~/.config/envsetup/config.yaml)的内容
+type Settings struct {
+	Network NetworkSettings `yaml:"network"`
+	// 自定义的代码平台, 键为名称; github、gitee、gitlab、gitea 可以直接使用
+	Sources map[string]SourceSettings `yaml:"sources"`
+	// 改为从其他代码平台获取的仓库, 键为GitHub上的 owner/repo
+	Repos map[string]RepoSettings `yaml:"repos"`
+	// 软件包名, 键为逻辑名称(如 fd), 值的键为发行版ID(如 ubuntu)或包管理器(如 apt-get), 优先于内置的软件包名
+	Packages map[string]map[string]string `yaml:"packages"`
+}
+
+// SourceSettings 描述一个代码平台
+type SourceSettings struct {
+	// 平台类型: github、gitee、gitlab、gitea
+	Type string `yaml:"type"`
+	// 平台地址, 为空时使用该类型的公共服务地址
+	URL string `yaml:"url"`
+	// 访问API和私有仓库的令牌
+	Token string `yaml:"token"`
+}
+
+// RepoSettings 指定仓库从哪个平台获取
+type RepoSettings struct {
+	// 代码平台的名称
+	Source string `yaml:"source"`
+	// 仓库在该平台上的 owner/repo, 为空时与GitHub上的相同
+	Path string `yaml:"path"`
+}
+
+// 不需要在 sources 中定义就可以使用的代码平台
+var builtinSources = []string{"github", "gitee", "gitlab", "gitea"}
+
+func isBuiltinSource(name string) bool {
+	for _, builtin := range builtinSources {
+		if name == builtin {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSource 返回名称为name的代码平台, 内置平台未在 sources 中定义时使用其公共服务
+func (s *Settings) GetSource(name string) (SourceSettings, bool) {
+	if source, ok := s.Sources[name]; ok {
+		return source, true
+	}
+	if isBuiltinSource(name) {
+		return SourceSettings{Type: name}, true
+	}
+	return SourceSettings{}, false
+}
+
+// NetworkSettings 控制下载、克隆等网络操作
+type NetworkSettings struct {
+	// 每个下载源失败后的重试次数, 默认2次
+	Retries *int `yaml:"retries"`
+	// 第一次重试前的等待时间, 之后每次翻倍, 默认1s
+	RetryDelay time.Duration `yaml:"retry_delay"`
+	// --github-proxy 和直连都失败后依次尝试的GitHub镜像, 也是 --github-proxy=auto 测速的候选镜像
+	Mirrors []string `yaml:"mirrors"`
+	// 镜像测速下载的文件, 需要是 github.com 上的地址
+	ProbeURL string `yaml:"probe_url"`
+	// 镜像测速结果的有效期, 默认6h
+	ProbeTTL time.Duration `yaml:"probe_ttl"`
+}
+
+const (
+	defaultRetries    = 2
+	defaultRetryDelay = time.Second
+	defaultProbeTTL   = 6 * time.Hour
+)
+
+// GetRetries 返回重试次数, 未配置时使用默认值
+func (n *NetworkSettings) GetRetries() int {
+	if n.Retries == nil {
+		return defaultRetries
+	}
+	return *n.Retries
+}
+
+// GetRetryDelay 返回第一次重试前的等待时间, 未配置时使用默认值
+func (n *NetworkSettings) GetRetryDelay() time.Duration {
+	if n.RetryDelay <= 0 {
+		return defaultRetryDelay
+	}
+	return n.RetryDelay
+}
+
+// GetProbeTTL 返回镜像测速结果的有效期, 未配置时使用默认值
+func (n *NetworkSettings) GetProbeTTL() time.Duration {
+	if n.ProbeTTL <= 0 {
+		return defaultProbeTTL
+	}
+	return n.ProbeTTL
+}
+
+// LoadSettings 读取用户配置文件, 文件不存在时返回默认配置
+func LoadSettings(path string) (*Settings, error) {
+	settings := &Settings{}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件%s失败: %w", path, err)
+	}
+	if err := yaml.Unmarshal(content, settings); err != nil {
+		return nil, fmt.Errorf("解析配置文件%s失败: %w", path, err)
+	}
+	if settings.Network.Retries != nil && *settings.Network.Retries < 0 {
+		return nil, fmt.Errorf("配置文件%s中network.retries不能小于0", path)
+	}
+	for name, source := range settings.Sources {
+		if !isBuiltinSource(source.Type) {
+			return nil, fmt.Errorf("配置文件%s中代码平台%s的type无效: %q (可选值: %s)", path, name, source.Type, strings.Join(builtinSources, ", "))
+		}
+	}
+	for repo, rs := range settings.Repos {
+		if len(strings.Split(repo, "/")) != 2 {
+			return nil, fmt.Errorf("配置文件%s中的仓库%s无效, 格式为 owner/repo", path, repo)
+		}
+		if rs.Path != "" && len(strings.Split(rs.Path, "/")) < 2 {
+			return nil, fmt.Errorf("配置文件%s中仓库%s的path无效, 格式为 owner/repo", path, repo)
+		}
+		if _, ok := settings.GetSource(rs.Source); !ok {
+			return nil, fmt.Errorf("配置文件%s中仓库%s使用的代码平台%s未定义", path, repo, rs.Source)
+		}
+	}
+	return settings, nil
+}
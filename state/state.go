@@ -0,0 +1,174 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FileRecord 记录一个由envsetup写入的文件或目录
+type FileRecord struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// RcBlock 记录写入shell配置文件中的配置块
+type RcBlock struct {
+	File  string `json:"file"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// Record 记录一个应用的安装状态
+type Record struct {
+	Name        string       `json:"name"`
+	Version     string       `json:"version"`
+	Source      string       `json:"source"`
+	InstalledAt time.Time    `json:"installed_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Files       []FileRecord `json:"files"`
+	RcBlocks    []RcBlock    `json:"rc_blocks,omitempty"`
+}
+
+// Store 是保存在本地的安装状态数据库
+type Store struct {
+	path string
+	mu   sync.Mutex
+	apps map[string]*Record
+}
+
+type storeFile struct {
+	Apps map[string]*Record `json:"apps"`
+}
+
+// NewStore 创建一个空的状态库, 保存时写入path
+func NewStore(path string) *Store {
+	return &Store{
+		path: path,
+		apps: make(map[string]*Record),
+	}
+}
+
+// Open 打开状态文件, 文件不存在时返回空的状态库
+func Open(path string) (*Store, error) {
+	s := NewStore(path)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取状态文件%s失败: %w", path, err)
+	}
+
+	data := storeFile{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("解析状态文件%s失败: %w", path, err)
+	}
+	if data.Apps != nil {
+		s.apps = data.Apps
+	}
+	return s, nil
+}
+
+// Path 返回状态文件路径
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Get 获取应用的安装记录
+func (s *Store) Get(name string) (*Record, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.apps[name]
+	return rec, ok
+}
+
+// List 按名称顺序返回所有安装记录
+func (s *Store) List() []*Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]*Record, 0, len(s.apps))
+	for _, rec := range s.apps {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Name < records[j].Name })
+	return records
+}
+
+// Put 保存应用的安装记录, 已有记录时保留首次安装时间
+func (s *Store) Put(rec *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	rec.UpdatedAt = now
+	if old, ok := s.apps[rec.Name]; ok && !old.InstalledAt.IsZero() {
+		rec.InstalledAt = old.InstalledAt
+	} else {
+		rec.InstalledAt = now
+	}
+	s.apps[rec.Name] = rec
+	return s.save()
+}
+
+// Remove 删除应用的安装记录
+func (s *Store) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[name]; !ok {
+		return nil
+	}
+	delete(s.apps, name)
+	return s.save()
+}
+
+// save 先写临时文件再重命名, 避免写入中断导致状态文件损坏
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(storeFile{Apps: s.apps}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// NewFileRecord 生成文件记录, 普通文件会同时记录其SHA256
+func NewFileRecord(path string) FileRecord {
+	rec := FileRecord{Path: path}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return rec
+	}
+	if sum, err := FileSHA256(path); err == nil {
+		rec.SHA256 = sum
+	}
+	return rec
+}
+
+// FileSHA256 计算文件的SHA256
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
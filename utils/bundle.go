@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/mholt/archiver/v3"
+	"github.com/sirupsen/logrus"
+)
+
+const bundleManifestName = "manifest.json"
+
+// BundleResource 描述应用安装时需要从GitHub获取的资源, Asset 为空时表示克隆整个仓库
+type BundleResource struct {
+	Owner string
+	Repo  string
+	Tag   string
+	Asset string
+}
+
+// BundleRelease 是离线安装包中的一个Release文件
+type BundleRelease struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	Tag   string `json:"tag"`
+	Asset string `json:"asset"`
+	Path  string `json:"path"`
+	// Release发布的SHA256, 没有发布校验和时为空
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// BundleRepo 是离线安装包中的一个Git仓库镜像(裸仓库)
+type BundleRepo struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	Path  string `json:"path"`
+}
+
+// BundleManifest 描述离线安装包的内容
+type BundleManifest struct {
+	OS        string           `json:"os"`
+	Arch      string           `json:"arch"`
+	Apps      []string         `json:"apps"`
+	CreatedAt time.Time        `json:"created_at"`
+	Releases  []*BundleRelease `json:"releases"`
+	Repos     []*BundleRepo    `json:"repos"`
+}
+
+// Bundle 是解压后的离线安装包
+type Bundle struct {
+	dir      string
+	manifest *BundleManifest
+}
+
+var activeBundle *Bundle
+
+// SetBundle 设置安装使用的离线安装包, 设置后Release下载和仓库克隆都从安装包读取
+func SetBundle(bundle *Bundle) {
+	activeBundle = bundle
+}
+
+// CreateBundle 下载resources中的Release文件并制作仓库镜像, 打包为离线安装包out
+func CreateBundle(resources []BundleResource, manifest *BundleManifest, out, httpsProxy, githubProxy string, logger *logrus.Logger) error {
+	if IsDryRun() {
+		for _, res := range resources {
+			githubInfo := NewGithubRepoInfo(res.Owner, res.Repo, httpsProxy, githubProxy, logger)
+			if res.Asset == "" {
+				recordStep(logger, "克隆", githubInfo.GetRepoUrl(), "制作仓库镜像")
+			} else {
+				recordStep(logger, "下载", githubInfo.GetReleaseFileUrl(res.Asset, res.Tag), "加入离线安装包")
+			}
+		}
+		recordStep(logger, "写入文件", out, "离线安装包")
+		return nil
+	}
+
+	staging, err := os.MkdirTemp("", "envsetup-bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	for _, res := range resources {
+		githubInfo := NewGithubRepoInfo(res.Owner, res.Repo, httpsProxy, githubProxy, logger)
+		if res.Asset == "" {
+			rel := filepath.Join("repos", res.Owner, res.Repo+".git")
+			if err := githubInfo.MirrorRepo(filepath.Join(staging, rel)); err != nil {
+				return err
+			}
+			manifest.Repos = append(manifest.Repos, &BundleRepo{Owner: res.Owner, Repo: res.Repo, Path: rel})
+			continue
+		}
+
+		rel := filepath.Join("releases", res.Owner, res.Repo, res.Tag, res.Asset)
+		dst := filepath.Join(staging, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := githubInfo.DownloadReleaseLatestFile(dst, res.Asset, res.Tag); err != nil {
+			return err
+		}
+		release := &BundleRelease{Owner: res.Owner, Repo: res.Repo, Tag: res.Tag, Asset: res.Asset, Path: rel}
+		// 打包时校验文件, 并记录Release发布的校验和供离线安装时使用
+		sum, _, err := githubInfo.FindReleaseChecksum(res.Asset, res.Tag)
+		switch {
+		case errors.Is(err, ErrNoChecksum):
+			logger.Warnf("%s %s未发布SHA256校验和", res.Tag, res.Asset)
+		case err != nil:
+			return err
+		default:
+			if err := githubInfo.VerifyReleaseFile(dst, res.Asset, res.Tag, sum); err != nil {
+				return err
+			}
+			release.SHA256 = sum
+		}
+		manifest.Releases = append(manifest.Releases, release)
+	}
+
+	manifest.CreatedAt = time.Now()
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(staging, bundleManifestName), content, 0o644); err != nil {
+		return err
+	}
+
+	sources := []string{filepath.Join(staging, bundleManifestName)}
+	for _, dir := range []string{"releases", "repos"} {
+		if DirectoryExists(filepath.Join(staging, dir)) {
+			sources = append(sources, filepath.Join(staging, dir))
+		}
+	}
+	if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := archiver.Archive(sources, out); err != nil {
+		logger.Errorf("生成离线安装包%s失败:%s", out, err)
+		return err
+	}
+	logger.Infof("离线安装包已生成:%s", out)
+	return nil
+}
+
+// OpenBundle 将离线安装包解压到临时目录, 使用完后需要调用 Close 清理
+func OpenBundle(path string) (*Bundle, error) {
+	dir, err := os.MkdirTemp("", "envsetup-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	if err := archiver.Unarchive(path, dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("解压离线安装包%s失败: %w", path, err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, bundleManifestName))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("离线安装包%s缺少%s: %w", path, bundleManifestName, err)
+	}
+	manifest := &BundleManifest{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("解析离线安装包%s失败: %w", path, err)
+	}
+	return &Bundle{dir: dir, manifest: manifest}, nil
+}
+
+func (b *Bundle) Manifest() *BundleManifest {
+	return b.manifest
+}
+
+// Close 删除解压的临时目录
+func (b *Bundle) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+func (b *Bundle) release(owner, repo, tag, asset string) *BundleRelease {
+	for _, r := range b.manifest.Releases {
+		if r.Owner == owner && r.Repo == repo && r.Asset == asset && (tag == "" || r.Tag == tag) {
+			return r
+		}
+	}
+	return nil
+}
+
+// releaseInfo 根据安装包中的文件生成Release信息, tag为空时返回安装包中的版本
+func (b *Bundle) releaseInfo(owner, repo, tag string) (*Release, error) {
+	release := &Release{TagName: tag}
+	for _, r := range b.manifest.Releases {
+		if r.Owner != owner || r.Repo != repo || (release.TagName != "" && r.Tag != release.TagName) {
+			continue
+		}
+		release.TagName = r.Tag
+		release.Assets = append(release.Assets, ReleaseAsset{Name: r.Asset})
+	}
+	if len(release.Assets) == 0 {
+		if tag == "" {
+			return nil, fmt.Errorf("离线安装包中没有%s/%s的Release文件", owner, repo)
+		}
+		return nil, fmt.Errorf("离线安装包中没有%s/%s %s的Release文件", owner, repo, tag)
+	}
+	return release, nil
+}
+
+// repoPath 返回安装包中owner/repo镜像的本地路径
+func (b *Bundle) repoPath(owner, repo string) (string, bool) {
+	for _, r := range b.manifest.Repos {
+		if r.Owner == owner && r.Repo == repo {
+			return filepath.Join(b.dir, r.Path), true
+		}
+	}
+	return "", false
+}
+
+// MirrorRepo 将仓库克隆为裸仓库, 用于制作离线安装包
+func (g *GithubRepoInfo) MirrorRepo(dstPath string) error {
+	g.logger.Infof("制作repo:%s的镜像:%s", g.repo, dstPath)
+	progress, _ := OutputWriters(g.logger)
+	if _, err := git.PlainClone(dstPath, true, &git.CloneOptions{
+		Depth:    1,
+		URL:      g.GetRepoUrl(),
+		Auth:     g.source.GitAuth(),
+		Progress: progress,
+	}); err != nil {
+		g.logger.Errorf("制作repo:%s的镜像失败:%s", g.repo, err)
+		return err
+	}
+	return nil
+}
+
+// cloneFromBundle 从离线安装包中的镜像克隆仓库, 并将origin改为真实的仓库地址, 便于联网后更新
+func (g *GithubRepoInfo) cloneFromBundle(mirror, dstPath string) error {
+	g.logger.Infof("从离线安装包克隆repo:%s到本地:%s", g.repo, dstPath)
+	if IsDryRun() {
+		recordStep(g.logger, "克隆", mirror, fmt.Sprintf("保存到: %s", dstPath))
+		return nil
+	}
+	repo, err := git.PlainClone(dstPath, false, &git.CloneOptions{URL: mirror})
+	if err != nil {
+		g.logger.Errorf("Clone repo:%s失败:%s", g.repo, err)
+		return err
+	}
+	if err := g.resetOrigin(repo); err != nil {
+		return err
+	}
+	g.logger.Infof("Clone repo:%s成功", g.repo)
+	return nil
+}
+
+// copyFromBundle 将离线安装包中的Release文件复制到dstFileName
+func (g *GithubRepoInfo) copyFromBundle(dstFileName, srcFileName, tagName string) error {
+	release := activeBundle.release(g.ower, g.repo, tagName, srcFileName)
+	if release == nil {
+		return fmt.Errorf("离线安装包中没有%s/%s %s的文件%s", g.ower, g.repo, tagName, srcFileName)
+	}
+	src := filepath.Join(activeBundle.dir, release.Path)
+	if IsDryRun() {
+		recordStep(g.logger, "写入文件", dstFileName, fmt.Sprintf("来自离线安装包: %s", release.Path))
+		return nil
+	}
+	if err := copyFile(src, dstFileName, 0o644); err != nil {
+		return err
+	}
+	g.logger.Infof("已从离线安装包获取文件%s", srcFileName)
+	return nil
+}
+
+// bundleChecksum 返回离线安装包清单中记录的SHA256
+func (g *GithubRepoInfo) bundleChecksum(srcFileName, tagName string) (string, error) {
+	release := activeBundle.release(g.ower, g.repo, tagName, srcFileName)
+	if release == nil || release.SHA256 == "" {
+		return "", ErrNoChecksum
+	}
+	return release.SHA256, nil
+}
@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bookandmusic/envsetup/state"
+)
+
+// CacheKey 标识一个Release文件
+type CacheKey struct {
+	Owner string `json:"owner"`
+	Repo  string `json:"repo"`
+	Tag   string `json:"tag"`
+	Asset string `json:"asset"`
+}
+
+func (k CacheKey) path() string {
+	return filepath.Join(k.Owner, k.Repo, k.Tag, k.Asset)
+}
+
+// CacheEntry 记录一个已缓存的Release文件, 文件内容按SHA256保存在 blobs 目录
+type CacheEntry struct {
+	CacheKey
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	URL      string    `json:"url"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// DownloadCache 是按 owner/repo/tag/asset 索引、按内容寻址的本地下载缓存:
+//
+//	index/<owner>/<repo>/<tag>/<asset>.json  缓存记录
+//	blobs/<sha256>                           文件内容
+//	partial/<owner>/<repo>/<tag>/<asset>     未完成的下载, 下次继续
+type DownloadCache struct {
+	dir    string
+	logger *logrus.Logger
+}
+
+var downloadCache *DownloadCache
+
+// SetDownloadCache 设置Release下载使用的缓存, 为nil时不使用缓存
+func SetDownloadCache(cache *DownloadCache) {
+	downloadCache = cache
+}
+
+func NewDownloadCache(dir string, logger *logrus.Logger) *DownloadCache {
+	return &DownloadCache{dir: dir, logger: logger}
+}
+
+func (c *DownloadCache) Dir() string {
+	return c.dir
+}
+
+func (c *DownloadCache) indexPath(key CacheKey) string {
+	return filepath.Join(c.dir, "index", key.path()+".json")
+}
+
+func (c *DownloadCache) blobPath(sum string) string {
+	return filepath.Join(c.dir, "blobs", sum)
+}
+
+// PartialPath 返回未完成下载的保存路径
+func (c *DownloadCache) PartialPath(key CacheKey) string {
+	return filepath.Join(c.dir, "partial", key.path())
+}
+
+// Lookup 查找已缓存的文件, 文件内容与记录的SHA256不一致时视为未缓存
+func (c *DownloadCache) Lookup(key CacheKey) (string, bool) {
+	entry, err := c.readEntry(c.indexPath(key))
+	if err != nil {
+		return "", false
+	}
+	blob := c.blobPath(entry.SHA256)
+	sum, err := state.FileSHA256(blob)
+	if err != nil || sum != entry.SHA256 {
+		c.logger.Warnf("缓存文件%s已损坏, 将重新下载", blob)
+		c.Evict(key)
+		return "", false
+	}
+	return blob, true
+}
+
+// Store 将下载完成的文件移入缓存, 返回缓存文件路径
+func (c *DownloadCache) Store(key CacheKey, srcPath, url string) (string, error) {
+	sum, err := state.FileSHA256(srcPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+
+	blob := c.blobPath(sum)
+	if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(srcPath, blob); err != nil {
+		return "", err
+	}
+
+	entry := CacheEntry{CacheKey: key, SHA256: sum, Size: info.Size(), URL: url, CachedAt: time.Now()}
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	indexPath := c.indexPath(key)
+	if err := os.MkdirAll(filepath.Dir(indexPath), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(indexPath, content, 0o644); err != nil {
+		return "", err
+	}
+	return blob, nil
+}
+
+// Evict 删除一个缓存记录, 内容文件在 prune 时清理
+func (c *DownloadCache) Evict(key CacheKey) {
+	if err := os.Remove(c.indexPath(key)); err != nil && !os.IsNotExist(err) {
+		c.logger.Warnf("删除缓存记录%s失败:%s", key.path(), err)
+	}
+}
+
+// List 返回所有缓存记录
+func (c *DownloadCache) List() ([]*CacheEntry, error) {
+	var entries []*CacheEntry
+	root := filepath.Join(c.dir, "index")
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		entry, err := c.readEntry(path)
+		if err != nil {
+			c.logger.Warnf("读取缓存记录%s失败:%s", path, err)
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path() < entries[j].path() })
+	return entries, err
+}
+
+// Prune 删除早于maxAge的缓存记录、未完成的下载以及不再被引用的内容文件, 返回释放的空间
+func (c *DownloadCache) Prune(maxAge time.Duration) (int64, error) {
+	entries, err := c.List()
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[string]bool)
+	for _, entry := range entries {
+		if time.Since(entry.CachedAt) > maxAge {
+			c.Evict(entry.CacheKey)
+			continue
+		}
+		used[entry.SHA256] = true
+	}
+
+	var freed int64
+	partialDir := filepath.Join(c.dir, "partial")
+	freed += dirSize(partialDir)
+	if err := os.RemoveAll(partialDir); err != nil {
+		return freed, err
+	}
+
+	blobs, err := os.ReadDir(filepath.Join(c.dir, "blobs"))
+	if err != nil && !os.IsNotExist(err) {
+		return freed, err
+	}
+	for _, blob := range blobs {
+		if used[blob.Name()] {
+			continue
+		}
+		path := c.blobPath(blob.Name())
+		freed += dirSize(path)
+		if err := os.Remove(path); err != nil {
+			return freed, err
+		}
+	}
+	return freed, nil
+}
+
+// Clear 删除全部缓存
+func (c *DownloadCache) Clear() (int64, error) {
+	freed := dirSize(c.dir)
+	return freed, os.RemoveAll(c.dir)
+}
+
+func (c *DownloadCache) readEntry(path string) (*CacheEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &CacheEntry{}
+	if err := json.Unmarshal(content, entry); err != nil {
+		return nil, err
+	}
+	if !isSHA256(entry.SHA256) {
+		return nil, fmt.Errorf("缓存记录中的SHA256无效: %q", entry.SHA256)
+	}
+	return entry, nil
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// downloadWithResume 下载文件到partPath, partPath已存在时使用HTTP Range从断点继续下载
+func downloadWithResume(client *http.Client, url, partPath string, logger *logrus.Logger) error {
+	if err := os.MkdirAll(filepath.Dir(partPath), 0o755); err != nil {
+		return err
+	}
+
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		logger.Infof("从断点%d字节处继续下载", offset)
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// 断点已超出文件大小, 说明未完成的文件已失效, 重新下载
+		logger.Warnf("断点续传失败, 重新下载")
+		if err := os.Remove(partPath); err != nil {
+			return err
+		}
+		return downloadWithResume(client, url, partPath, logger)
+	case resp.StatusCode == http.StatusOK:
+		// 服务器不支持Range时会返回完整内容
+		flags |= os.O_TRUNC
+	default:
+		return &HTTPStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	f, err := os.OpenFile(partPath, flags, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
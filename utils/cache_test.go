@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadWithResume(t *testing.T) {
+	const content = "0123456789abcdefghij"
+	tests := []struct {
+		name string
+		// 下载前已存在的未完成文件, 为空表示没有
+		partial string
+		// 服务器是否支持Range请求
+		noRange bool
+		// 服务器返回的状态码, 为0时按Range正常响应
+		status     int
+		wantRange  []string
+		want       string
+		wantStatus int
+	}{
+		{
+			name:      "没有未完成的文件时完整下载",
+			wantRange: []string{""},
+			want:      content,
+		},
+		{
+			name:      "206时从断点继续",
+			partial:   content[:8],
+			wantRange: []string{"bytes=8-"},
+			want:      content,
+		},
+		{
+			name:      "服务器不支持Range返回200时重新写入",
+			partial:   "stale",
+			noRange:   true,
+			wantRange: []string{"bytes=5-"},
+			want:      content,
+		},
+		{
+			name:      "416时删除未完成的文件重新下载",
+			partial:   content + "extra",
+			wantRange: []string{"bytes=25-", ""},
+			want:      content,
+		},
+		{
+			name:       "404返回HTTPStatusError",
+			status:     http.StatusNotFound,
+			wantRange:  []string{""},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rangeHeader := r.Header.Get("Range")
+				ranges = append(ranges, rangeHeader)
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				if rangeHeader == "" || tt.noRange {
+					fmt.Fprint(w, content)
+					return
+				}
+				var offset int
+				fmt.Sscanf(rangeHeader, "bytes=%d-", &offset)
+				if offset >= len(content) {
+					w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+					return
+				}
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(content)-1, len(content)))
+				w.WriteHeader(http.StatusPartialContent)
+				fmt.Fprint(w, content[offset:])
+			}))
+			defer server.Close()
+
+			partPath := filepath.Join(t.TempDir(), "partial", "file")
+			if tt.partial != "" {
+				os.MkdirAll(filepath.Dir(partPath), 0o755)
+				if err := os.WriteFile(partPath, []byte(tt.partial), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := downloadWithResume(server.Client(), server.URL, partPath, newTestLogger())
+			if strings.Join(ranges, ",") != strings.Join(tt.wantRange, ",") {
+				t.Errorf("Range请求头 = %q, want %q", ranges, tt.wantRange)
+			}
+			if tt.wantStatus != 0 {
+				var statusErr *HTTPStatusError
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+					t.Fatalf("downloadWithResume() error = %v, want HTTPStatusError %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("downloadWithResume() error = %v", err)
+			}
+			got, err := os.ReadFile(partPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("文件内容 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadCacheSkipsInvalidEntries(t *testing.T) {
+	cache := NewDownloadCache(t.TempDir(), newTestLogger())
+	src := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(src, []byte("content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	key := CacheKey{Owner: "o", Repo: "r", Tag: "v1", Asset: "file"}
+	if _, err := cache.Store(key, src, "https://example.com/file"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 缺少SHA256和SHA256过短的记录都应被跳过
+	for _, asset := range []string{"missing", "short"} {
+		bad := CacheKey{Owner: "o", Repo: "r", Tag: "v1", Asset: asset}
+		body := `{"owner":"o","repo":"r","tag":"v1","asset":"` + asset + `"}`
+		if asset == "short" {
+			body = `{"owner":"o","repo":"r","tag":"v1","asset":"short","sha256":"abc"}`
+		}
+		os.MkdirAll(filepath.Dir(cache.indexPath(bad)), 0o755)
+		if err := os.WriteFile(cache.indexPath(bad), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cache.Lookup(bad); ok {
+			t.Errorf("Lookup(%s) 命中了无效的缓存记录", asset)
+		}
+	}
+
+	entries, err := cache.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Asset != "file" {
+		t.Fatalf("List() = %v, want 只有有效的记录", entries)
+	}
+	if _, ok := cache.Lookup(key); !ok {
+		t.Errorf("Lookup() 未命中有效的缓存记录")
+	}
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/bookandmusic/envsetup/state"
+)
+
+// ErrNoChecksum 表示Release中没有发布可用于校验的哈希值
+var ErrNoChecksum = errors.New("没有可用的SHA256校验和")
+
+// ChecksumMismatchError 表示下载文件的哈希值与期望值不一致
+type ChecksumMismatchError struct {
+	File     string
+	Expected string
+	Actual   string
+	Source   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("文件%s的SHA256校验失败: 期望%s(来自%s), 实际%s", e.File, e.Expected, e.Source, e.Actual)
+}
+
+// checksumFileNames 返回可能包含srcFileName校验和的文件名: 常见的命名, 以及Release中名称带有
+// checksum、sha256 的文件(例如 fzf_0.56.3_checksums.txt)
+func checksumFileNames(srcFileName string, release *Release) []string {
+	names := []string{
+		srcFileName + ".sha256",
+		srcFileName + ".sha256sum",
+		"checksums.txt",
+		"sha256sums.txt",
+		"SHA256SUMS",
+		"SHA256SUMS.txt",
+	}
+	if release == nil {
+		return names
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		seen[name] = true
+	}
+	for _, asset := range release.Assets {
+		lower := strings.ToLower(asset.Name)
+		if seen[asset.Name] || !(strings.Contains(lower, "checksum") || strings.Contains(lower, "sha256")) {
+			continue
+		}
+		// 其他文件单独的校验和文件不会包含srcFileName
+		if strings.HasSuffix(lower, ".sha256") || strings.HasSuffix(lower, ".sha256sum") {
+			continue
+		}
+		seen[asset.Name] = true
+		names = append(names, asset.Name)
+	}
+	return names
+}
+
+// FindReleaseChecksum 查找srcFileName的SHA256: 优先使用GitHub API返回的文件摘要, 其次是Release中发布的校验和文件。
+// 校验和总是直接从GitHub获取, 不经过 --github-proxy 和镜像, 避免镜像同时篡改文件和校验和
+func (g *GithubRepoInfo) FindReleaseChecksum(srcFileName, tagName string) (string, string, error) {
+	if activeBundle != nil {
+		sum, err := g.bundleChecksum(srcFileName, tagName)
+		return sum, "离线安装包清单", err
+	}
+	release, err := g.checksumRelease(tagName)
+	if err == nil {
+		sum, err := assetDigest(release, srcFileName)
+		if err == nil {
+			return sum, "GitHub API", nil
+		}
+		g.logger.Debugf("获取文件%s的摘要失败:%s", srcFileName, err)
+	} else {
+		g.logger.Debugf("获取文件%s的摘要失败:%s", srcFileName, err)
+	}
+	for _, name := range checksumFileNames(srcFileName, release) {
+		content, err := g.fetch(g.GetReleaseFileUrl(name, tagName))
+		if err != nil {
+			g.logger.Debugf("获取校验和文件%s失败:%s", name, err)
+			continue
+		}
+		if sum := parseChecksum(content, srcFileName); sum != "" {
+			return sum, name, nil
+		}
+	}
+	return "", "", ErrNoChecksum
+}
+
+func (g *GithubRepoInfo) checksumRelease(tagName string) (*Release, error) {
+	if tagName == "" {
+		return g.GetLatestRelease()
+	}
+	return g.GetReleaseByTag(tagName)
+}
+
+// assetDigest 返回GitHub API中记录的文件SHA256
+func assetDigest(release *Release, srcFileName string) (string, error) {
+	for _, asset := range release.Assets {
+		if asset.Name != srcFileName {
+			continue
+		}
+		sum := strings.TrimPrefix(asset.Digest, "sha256:")
+		if !isSHA256(sum) {
+			return "", ErrNoChecksum
+		}
+		return strings.ToLower(sum), nil
+	}
+	return "", fmt.Errorf("Release %s中没有文件%s", release.TagName, srcFileName)
+}
+
+// VerifyReleaseFile 校验下载文件的SHA256。pinned 不为空时优先使用内置的哈希值,
+// 否则使用Release中发布的校验和文件
+func (g *GithubRepoInfo) VerifyReleaseFile(filePath, srcFileName, tagName, pinned string) error {
+	if IsDryRun() {
+		recordStep(g.logger, "校验", filePath, "SHA256")
+		return nil
+	}
+
+	expected, source := pinned, "内置哈希"
+	if expected == "" {
+		var err error
+		expected, source, err = g.FindReleaseChecksum(srcFileName, tagName)
+		if err != nil {
+			return err
+		}
+	}
+
+	actual, err := state.FileSHA256(filePath)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return &ChecksumMismatchError{File: srcFileName, Expected: expected, Actual: actual, Source: source}
+	}
+	g.logger.Infof("文件%s的SHA256校验通过(来自%s)", srcFileName, source)
+	return nil
+}
+
+func (g *GithubRepoInfo) fetch(rawUrl string) ([]byte, error) {
+	resp, err := g.httpClinet.Get(rawUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("响应状态码: %d", resp.StatusCode)
+	}
+	// 校验和文件很小, 限制读取大小避免误下载大文件
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseChecksum 解析 sha256sum 格式的校验和文件, 只有一个哈希值的文件直接返回该值
+func parseChecksum(content []byte, srcFileName string) string {
+	var lines [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && isSHA256(fields[0]) {
+			lines = append(lines, fields)
+		}
+	}
+	for _, fields := range lines {
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimPrefix(fields[len(fields)-1], "*")
+		if name == srcFileName || filepath.Base(name) == srcFileName {
+			return strings.ToLower(fields[0])
+		}
+	}
+	if len(lines) == 1 && len(lines[0]) == 1 {
+		return strings.ToLower(lines[0][0])
+	}
+	return ""
+}
+
+func isSHA256(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
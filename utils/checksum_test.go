@@ -0,0 +1,102 @@
+package utils
+
+import "testing"
+
+func TestParseChecksum(t *testing.T) {
+	const (
+		sumA = "0f343b0931126a20f133d67c2b018a3b1f1b0f9c1f5b5b7f5a5b7b0b1d1c1e1f"
+		sumB = "9e107d9d372bb6826bd81d3542a419d6e2b2d8f7e5b3b8b9e7f0c1a2b3c4d5e6"
+	)
+	tests := []struct {
+		name    string
+		content string
+		file    string
+		want    string
+	}{
+		{
+			name:    "sha256sum文本模式",
+			content: sumA + "  tool-linux-amd64.tar.gz\n" + sumB + "  tool-darwin-arm64.tar.gz\n",
+			file:    "tool-darwin-arm64.tar.gz",
+			want:    sumB,
+		},
+		{
+			name:    "sha256sum二进制模式的*name",
+			content: sumA + " *tool-linux-amd64.tar.gz\n" + sumB + " *tool-darwin-arm64.tar.gz\n",
+			file:    "tool-linux-amd64.tar.gz",
+			want:    sumA,
+		},
+		{
+			name:    "带路径的文件名",
+			content: sumA + "  dist/tool-linux-amd64.tar.gz\n",
+			file:    "tool-linux-amd64.tar.gz",
+			want:    sumA,
+		},
+		{
+			name:    "只有一个哈希值",
+			content: sumA + "\n",
+			file:    "tool-linux-amd64.tar.gz",
+			want:    sumA,
+		},
+		{
+			name:    "大写哈希转为小写",
+			content: "0F343B0931126A20F133D67C2B018A3B1F1B0F9C1F5B5B7F5A5B7B0B1D1C1E1F  tool\n",
+			file:    "tool",
+			want:    sumA,
+		},
+		{
+			name:    "忽略注释和无效行",
+			content: "# checksums\nnot-a-hash  tool\n\n" + sumB + "  tool\n",
+			file:    "tool",
+			want:    sumB,
+		},
+		{
+			name:    "没有匹配的文件",
+			content: sumA + "  tool-linux-amd64.tar.gz\n" + sumB + "  tool-darwin-arm64.tar.gz\n",
+			file:    "tool-windows-amd64.zip",
+			want:    "",
+		},
+		{
+			name:    "文件名只是前缀时不匹配",
+			content: sumA + "  tool.tar.gz.sig\n" + sumB + "  other\n",
+			file:    "tool.tar.gz",
+			want:    "",
+		},
+		{
+			name:    "哈希长度不对",
+			content: "0f343b09  tool\n",
+			file:    "tool",
+			want:    "",
+		},
+		{
+			name:    "空文件",
+			content: "",
+			file:    "tool",
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseChecksum([]byte(tt.content), tt.file); got != tt.want {
+				t.Errorf("parseChecksum() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecksumFileNamesFromRelease(t *testing.T) {
+	release := newTestRelease("fzf-0.56.3-linux_amd64.tar.gz", "fzf_0.56.3_checksums.txt", "checksums.txt", "other.tar.gz.sha256")
+	names := checksumFileNames("fzf-0.56.3-linux_amd64.tar.gz", release)
+	count := map[string]int{}
+	for _, name := range names {
+		count[name]++
+	}
+	if count["fzf_0.56.3_checksums.txt"] != 1 {
+		t.Errorf("checksumFileNames() = %v, 缺少Release中的校验和文件", names)
+	}
+	if count["checksums.txt"] != 1 {
+		t.Errorf("checksumFileNames() = %v, checksums.txt 重复", names)
+	}
+	if count["other.tar.gz.sha256"] != 0 {
+		t.Errorf("checksumFileNames() = %v, 不应包含其他文件单独的校验和", names)
+	}
+}
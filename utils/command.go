@@ -1,27 +1,75 @@
 package utils
 
 import (
-	"os"
+	"bytes"
+	"context"
 	"os/exec"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// GenerateCmd 在需要时为命令加上提权前缀(sudo 或 doas)。没有可用的提权工具时原样返回, 由命令自身报告权限错误
 func GenerateCmd(cmdStr string, isSudo, isRoot bool) string {
 	if !isSudo || isRoot {
 		return cmdStr
 	}
-	return "sudo " + cmdStr
+	prefix := privilegeCommand(PrivilegeTool())
+	if len(prefix) == 0 {
+		return cmdStr
+	}
+	return strings.Join(prefix, " ") + " " + cmdStr
 }
 
 func ExecCmd(cmdStr string, logger *logrus.Logger) error {
+	if IsDryRun() {
+		recordStep(logger, "执行命令", cmdStr, "")
+		return nil
+	}
+
 	// 使用 exec.Command 创建命令
 	cmd := exec.Command("bash", "-c", cmdStr)
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout, cmd.Stderr = OutputWriters(logger)
 
 	// 运行命令
 	logger.Infof(cmdStr)
 	return cmd.Run()
 }
+
+// CmdOutput 执行只读命令并返回其输出, dry-run 模式下同样会执行
+func CmdOutput(cmdStr string) (string, error) {
+	out, err := exec.Command("bash", "-c", cmdStr).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// ExecWithInput 执行可执行文件, 将input写入其标准输入并返回标准输出, 标准错误输出到日志。
+// readOnly 为false时在 dry-run 模式下只记录不执行; timeout 为0时不限制执行时间
+func ExecWithInput(path string, args []string, input []byte, readOnly bool, timeout time.Duration, logger *logrus.Logger) ([]byte, error) {
+	if !readOnly && IsDryRun() {
+		recordStep(logger, "执行命令", strings.TrimSpace(path+" "+strings.Join(args, " ")), "")
+		return nil, nil
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	var stdout bytes.Buffer
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	_, cmd.Stderr = OutputWriters(logger)
+	err := cmd.Run()
+	return stdout.Bytes(), err
+}
+
+var versionPattern = regexp.MustCompile(`v?\d+(\.\d+)+`)
+
+// ParseVersion 从命令输出中提取第一个版本号
+func ParseVersion(output string) string {
+	return versionPattern.FindString(output)
+}
@@ -2,19 +2,25 @@ package utils
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"regexp"
 
+	archiver "github.com/mholt/archiver/v3"
 	"github.com/sirupsen/logrus"
 )
 
-func UpdateConfigFiles(filePath, content string) error {
+func UpdateConfigFiles(filePath, content string, logger *logrus.Logger) error {
 	fileContent, err := os.ReadFile(filePath)
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
 	if !bytes.Contains(fileContent, []byte(content)) {
+		if IsDryRun() {
+			recordStep(logger, "追加配置", filePath, content)
+			return nil
+		}
 		f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0o644)
 		if err != nil {
 			return err
@@ -29,7 +35,7 @@ func UpdateConfigFiles(filePath, content string) error {
 }
 
 // 从指定文件中移除配置内容
-func RemoveConfigFromFile(filePath, contentPattern string) error {
+func RemoveConfigFromFile(filePath, contentPattern string, logger *logrus.Logger) error {
 	// 读取文件内容
 	fileContent, err := os.ReadFile(filePath)
 	if err != nil && !os.IsNotExist(err) {
@@ -44,6 +50,12 @@ func RemoveConfigFromFile(filePath, contentPattern string) error {
 
 	// 替换内容
 	newContent := re.ReplaceAllString(string(fileContent), "")
+	if IsDryRun() {
+		if newContent != string(fileContent) {
+			recordStep(logger, "移除配置", filePath, contentPattern)
+		}
+		return nil
+	}
 
 	// 写入新内容
 	if err := os.WriteFile(filePath, []byte(newContent), 0o644); err != nil {
@@ -54,6 +66,12 @@ func RemoveConfigFromFile(filePath, contentPattern string) error {
 }
 
 func RemoveFile(filePath string, logger *logrus.Logger) error {
+	if IsDryRun() {
+		if _, err := os.Lstat(filePath); err == nil {
+			recordStep(logger, "删除", filePath, "")
+		}
+		return nil
+	}
 	if err := os.RemoveAll(filePath); err != nil && !os.IsNotExist(err) {
 		logger.Errorf("清理文件%s失败:%s", filePath, err)
 		return err
@@ -63,6 +81,12 @@ func RemoveFile(filePath string, logger *logrus.Logger) error {
 }
 
 func Mkdir(path string, logger *logrus.Logger) error {
+	if IsDryRun() {
+		if !DirectoryExists(path) {
+			recordStep(logger, "创建目录", path, "")
+		}
+		return nil
+	}
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
 		logger.Errorf("创建目录%s失败:%s", path, err)
 		return err
@@ -70,3 +94,21 @@ func Mkdir(path string, logger *logrus.Logger) error {
 	logger.Infof("已创建目录:%s", path)
 	return nil
 }
+
+// WriteFile 写入文件内容
+func WriteFile(path string, content []byte, perm os.FileMode, logger *logrus.Logger) error {
+	if IsDryRun() {
+		recordStep(logger, "写入文件", path, fmt.Sprintf("权限: %#o", perm))
+		return nil
+	}
+	return os.WriteFile(path, content, perm)
+}
+
+// Unarchive 解压文件到指定目录
+func Unarchive(src, dst string, logger *logrus.Logger) error {
+	if IsDryRun() {
+		recordStep(logger, "解压", src, fmt.Sprintf("解压到: %s", dst))
+		return nil
+	}
+	return archiver.Unarchive(src, dst)
+}
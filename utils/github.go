@@ -1,17 +1,26 @@
 package utils
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"time"
 
-	"github.com/bitfield/script"
 	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/sirupsen/logrus"
 )
 
+// GithubRepoInfo 是代码平台上的一个仓库, 默认为GitHub, 也可以是 Source 支持的其他平台
 type GithubRepoInfo struct {
+	source      Source
 	ower        string
 	repo        string
 	httpsProxy  string
@@ -20,76 +29,193 @@ type GithubRepoInfo struct {
 	logger      *logrus.Logger
 }
 
-func generateHttpClient(httpsProxy string) *http.Client {
-	if httpsProxy == "" {
-		return &http.Client{}
-	}
-	proxyURL, err := url.Parse(httpsProxy) // 设置代理地址
-	if err != nil {
-		return &http.Client{}
+const (
+	// 建立连接(含TLS握手)的超时时间
+	connectTimeout = 15 * time.Second
+	// 连续没有收到数据的超时时间, 避免下载卡住时一直等待
+	idleReadTimeout = 60 * time.Second
+)
+
+// idleTimeoutConn 在每次读取前重新设置读超时, 连续idleReadTimeout没有数据时读取失败
+type idleTimeoutConn struct {
+	net.Conn
+}
+
+func (c *idleTimeoutConn) Read(b []byte) (int, error) {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(idleReadTimeout)); err != nil {
+		return 0, err
 	}
+	return c.Conn.Read(b)
+}
 
-	// 创建自定义的 Transport 以使用代理
-	transport := &http.Transport{
-		Proxy: http.ProxyURL(proxyURL),
+func generateHttpClient(httpsProxy string) *http.Client {
+	// 沿用默认Transport的连接池等设置, 只替换连接超时和读超时
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &idleTimeoutConn{Conn: conn}, nil
 	}
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = idleReadTimeout
 
-	// 创建使用自定义 Transport 的 HTTP 客户端
+	if httpsProxy != "" {
+		// 设置代理地址, 地址无效时不使用代理
+		if proxyURL, err := url.Parse(httpsProxy); err == nil {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
 	return &http.Client{
 		Transport: transport,
 	}
 }
 
+// CheckReachable 检测URL是否可以访问, 返回响应耗时
+func CheckReachable(rawUrl, httpsProxy string, timeout time.Duration) (time.Duration, error) {
+	client := generateHttpClient(httpsProxy)
+	client.Timeout = timeout
+
+	start := time.Now()
+	resp, err := client.Head(rawUrl)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return 0, fmt.Errorf("响应状态码: %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
+
+// NewGithubRepoInfo 创建GitHub仓库, 配置文件的repos中指定了其他平台时改为从该平台获取
 func NewGithubRepoInfo(ower, repo, httpsProxy, githubProxy string, logger *logrus.Logger) *GithubRepoInfo {
+	if rs, ok := repoSources[ower+"/"+repo]; ok {
+		logger.Debugf("仓库%s/%s改为从%s的%s/%s获取", ower, repo, rs.Source.Name(), rs.Owner, rs.Repo)
+		return NewRepoInfo(rs.Source, rs.Owner, rs.Repo, httpsProxy, githubProxy, logger)
+	}
+	return NewRepoInfo(GitHub, ower, repo, httpsProxy, githubProxy, logger)
+}
+
+// NewRepoInfo 创建source平台上的仓库, --github-proxy 和镜像只用于公共的GitHub
+func NewRepoInfo(source Source, ower, repo, httpsProxy, githubProxy string, logger *logrus.Logger) *GithubRepoInfo {
+	if !isPublicGithub(source) {
+		githubProxy = ""
+	}
 	return &GithubRepoInfo{
+		source:      source,
 		ower:        ower,
 		repo:        repo,
 		httpsProxy:  httpsProxy,
-		githubProxy: githubProxy,
+		githubProxy: ResolveGithubProxy(githubProxy, httpsProxy, logger),
 		httpClinet:  generateHttpClient(httpsProxy),
 		logger:      logger,
 	}
 }
 
-func (g *GithubRepoInfo) GetLatestReleaseTag() string {
-	api := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", g.ower, g.repo)
-	tagName, err := script.NewPipe().WithHTTPClient(g.httpClinet).Get(api).JQ(".tag_name").String()
+// isGithub 返回source是否为GitHub或GitHub Enterprise Server
+func isGithub(source Source) bool {
+	_, ok := source.(*GithubSource)
+	return ok
+}
+
+// isPublicGithub 返回source是否为公共的GitHub。代理和镜像只能访问公共GitHub,
+// 不能把GitHub Enterprise Server的内部地址发送给它们
+func isPublicGithub(source Source) bool {
+	return source == GitHub
+}
+
+// Source 返回仓库所在的代码平台
+func (g *GithubRepoInfo) Source() Source {
+	return g.source
+}
+
+// GetLatestReleaseTag 获取最新Release的版本, 被限流时返回 *RateLimitError
+func (g *GithubRepoInfo) GetLatestReleaseTag() (string, error) {
+	release, err := g.GetLatestRelease()
 	if err != nil {
-		g.logger.Errorf("获取%s最新版本失败:%s", g.repo, err)
-		return ""
-	}
-	if len(tagName) < 2 {
-		g.logger.Errorf("获取%s最新版本%s有误", g.repo, tagName)
-		return ""
+		return "", err
 	}
-	n := len(tagName)
-	g.logger.Infof("获取%s最新版本:%s", g.repo, tagName)
-	return tagName[1 : n-2]
+	g.logger.Infof("获取%s最新版本:%s", g.repo, release.TagName)
+	return release.TagName, nil
 }
 
+func (g *GithubRepoInfo) GetReleaseFileUrl(srcFileName, tagName string) string {
+	return g.source.ReleaseFileUrl(g.ower, g.repo, tagName, srcFileName)
+}
+
+func (g *GithubRepoInfo) cacheKey(srcFileName, tagName string) CacheKey {
+	return CacheKey{Owner: g.ower, Repo: g.repo, Tag: tagName, Asset: srcFileName}
+}
+
+// DownloadReleaseLatestFile 下载Release中的文件。启用下载缓存时优先使用缓存,
+// 未命中则下载到缓存目录(中断后下次从断点继续), 完成后再复制到dstFileName。
+// 下载失败时按重试策略重试, 并依次尝试各个下载源
 func (g *GithubRepoInfo) DownloadReleaseLatestFile(dstFileName, srcFileName, tagName string) error {
-	downloadUrl := fmt.Sprintf(
-		"https://github.com/%s/%s/releases/download/%s/%s",
-		g.ower, g.repo, tagName, srcFileName,
-	)
+	downloadUrl := g.GetReleaseFileUrl(srcFileName, tagName)
+	if activeBundle != nil {
+		return g.copyFromBundle(dstFileName, srcFileName, tagName)
+	}
 	g.logger.Infof("开始从repo: %s 的Release下载 %s %s", g.repo, tagName, srcFileName)
-	if g.githubProxy != "" {
-		downloadUrl = JoinURL(g.githubProxy, downloadUrl)
+	if IsDryRun() {
+		recordStep(g.logger, "下载", downloadUrl, fmt.Sprintf("保存到: %s", dstFileName))
+		return nil
+	}
+
+	// 未启用缓存时, 未完成的下载保存在临时目录, 避免在安装目录中留下文件
+	key := g.cacheKey(srcFileName, tagName)
+	partPath := filepath.Join(os.TempDir(), "envsetup-partial", key.path())
+	if downloadCache != nil {
+		if cached, ok := downloadCache.Lookup(key); ok {
+			g.logger.Infof("使用缓存的文件%s", cached)
+			return copyFile(cached, dstFileName, 0o644)
+		}
+		partPath = downloadCache.PartialPath(key)
 	}
-	_, err := script.NewPipe().WithHTTPClient(g.httpClinet).Get(downloadUrl).WriteFile(dstFileName)
+
+	err := g.withSources(fmt.Sprintf("下载文件%s", srcFileName), func(src string) error {
+		return downloadWithResume(g.httpClinet, sourceUrl(src, downloadUrl), partPath, g.logger)
+	})
 	if err != nil {
 		g.logger.Errorf("文件%s下载失败:%s", srcFileName, err)
 		return err
 	}
-	g.logger.Infof("文件%s下载成功", srcFileName)
-	return nil
+
+	if downloadCache == nil {
+		// 临时目录可能与dstFileName不在同一文件系统, 不能直接重命名
+		if err := copyFile(partPath, dstFileName, 0o644); err != nil {
+			return err
+		}
+		return os.Remove(partPath)
+	}
+	cached, err := downloadCache.Store(key, partPath, downloadUrl)
+	if err != nil {
+		g.logger.Errorf("文件%s写入缓存失败:%s", srcFileName, err)
+		return err
+	}
+	return copyFile(cached, dstFileName, 0o644)
+}
+
+// EvictCachedFile 从下载缓存中删除Release文件, 用于缓存内容校验失败时
+func (g *GithubRepoInfo) EvictCachedFile(srcFileName, tagName string) {
+	if downloadCache != nil {
+		downloadCache.Evict(g.cacheKey(srcFileName, tagName))
+	}
+}
+
+// GithubProxy 返回实际使用的GitHub代理, --github-proxy=auto 时为测速选出的镜像
+func (g *GithubRepoInfo) GithubProxy() string {
+	return g.githubProxy
+}
+
+func (g *GithubRepoInfo) GetOriginRepoUrl() string {
+	return g.source.RepoUrl(g.ower, g.repo)
 }
 
 func (g *GithubRepoInfo) GetRepoUrl() string {
-	repoUrl := fmt.Sprintf(
-		"https://github.com/%s/%s.git",
-		g.ower, g.repo,
-	)
+	repoUrl := g.GetOriginRepoUrl()
 	if g.githubProxy != "" {
 		repoUrl = JoinURL(g.githubProxy, repoUrl)
 	}
@@ -104,26 +230,54 @@ func (g *GithubRepoInfo) CloneRepo(dstPath string) error {
 			return nil
 		}
 		g.logger.Infof("本地路径:%s已存在,但不是正常的Git仓库,需要先删除再重新Clone", dstPath)
-		if err := os.RemoveAll(dstPath); err != nil && !os.IsNotExist(err) {
+		if err := RemoveFile(dstPath, g.logger); err != nil {
 			g.logger.Errorf("本地路径:%s清理失败:%s", dstPath, err)
 			return err
 		}
 	}
+	if activeBundle != nil {
+		mirror, ok := activeBundle.repoPath(g.ower, g.repo)
+		if !ok {
+			return fmt.Errorf("离线安装包中没有repo:%s/%s", g.ower, g.repo)
+		}
+		return g.cloneFromBundle(mirror, dstPath)
+	}
 	g.logger.Infof("本地不存在repo:%s,需要从远程 Clone 到本地:%s", g.repo, dstPath)
-	if _, err := git.PlainClone(dstPath, false, &git.CloneOptions{
-		Depth:    1,
-		URL:      g.GetRepoUrl(),
-		Progress: os.Stdout,
-	}); err != nil {
+	progress, _ := OutputWriters(g.logger)
+	if IsDryRun() {
+		recordStep(g.logger, "克隆", g.GetRepoUrl(), fmt.Sprintf("保存到: %s", dstPath))
+		return nil
+	}
+	err := g.withSources(fmt.Sprintf("Clone repo:%s", g.repo), func(src string) error {
+		// 克隆失败时 go-git 会清理 dstPath, 可以直接重试
+		repo, err := git.PlainClone(dstPath, false, &git.CloneOptions{
+			Depth:    1,
+			URL:      sourceUrl(src, g.GetOriginRepoUrl()),
+			Auth:     g.source.GitAuth(),
+			Progress: progress,
+		})
+		if err != nil {
+			return err
+		}
+		return g.resetOrigin(repo)
+	})
+	if err != nil {
 		g.logger.Errorf("Clone repo:%s失败:%s", g.repo, err)
 		return err
-	} else {
-		g.logger.Infof("Clone repo:%s成功", g.repo)
 	}
-
 	return nil
 }
 
+// resetOrigin 将origin设置为代码平台上的仓库地址, 更新时再根据下载源决定实际访问的地址
+func (g *GithubRepoInfo) resetOrigin(repo *git.Repository) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	cfg.Remotes["origin"].URLs = []string{g.GetOriginRepoUrl()}
+	return repo.SetConfig(cfg)
+}
+
 func (g *GithubRepoInfo) PullRepo(dstPath string) error {
 	g.logger.Infof("检测本地路径:%s是否存在repo:%s...", dstPath, g.repo)
 	if !DirectoryExists(dstPath) {
@@ -138,6 +292,10 @@ func (g *GithubRepoInfo) PullRepo(dstPath string) error {
 		return err
 	}
 	g.logger.Infof("Pulling最新变更到本地仓库:%s...", dstPath)
+	if IsDryRun() {
+		recordStep(g.logger, "拉取", dstPath, "git reset --hard && git clean -d --force && git pull")
+		return nil
+	}
 
 	// Get the working tree for the repository
 	worktree, err := repo.Worktree()
@@ -162,18 +320,29 @@ func (g *GithubRepoInfo) PullRepo(dstPath string) error {
 	g.logger.Infof("成功执行 git clean -d --force")
 
 	// Pull the latest changes from the remote repository
-	err = worktree.Pull(&git.PullOptions{
-		RemoteName:        "origin",
-		Progress:          os.Stdout,
-		Depth:             1,
-		Force:             true,
-		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
-	})
-	if err != nil {
+	progress, _ := OutputWriters(g.logger)
+	upToDate := false
+	err = g.withSources(fmt.Sprintf("Pull repo:%s", g.repo), func(src string) error {
+		err := worktree.Pull(&git.PullOptions{
+			RemoteName:        "origin",
+			RemoteURL:         sourceUrl(src, g.GetOriginRepoUrl()),
+			Auth:              g.source.GitAuth(),
+			Progress:          progress,
+			Depth:             1,
+			Force:             true,
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		})
 		if err == git.NoErrAlreadyUpToDate {
-			g.logger.Infof("本地仓库%s已经是最新的,无需拉取", dstPath)
+			upToDate = true
 			return nil
 		}
+		return err
+	})
+	if upToDate {
+		g.logger.Infof("本地仓库%s已经是最新的,无需拉取", dstPath)
+		return nil
+	}
+	if err != nil {
 		g.logger.Errorf("执行 git pull --rebase 错误: %s", err)
 		return err
 	}
@@ -182,3 +351,45 @@ func (g *GithubRepoInfo) PullRepo(dstPath string) error {
 	g.logger.Infof("Pull repo:%s成功", g.repo)
 	return nil
 }
+
+// GetRepoHead 返回本地仓库当前HEAD的提交哈希
+func GetRepoHead(dstPath string) (string, error) {
+	repo, err := git.PlainOpen(dstPath)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// GetRemoteHead 返回远程仓库默认分支的最新提交哈希
+func (g *GithubRepoInfo) GetRemoteHead() (string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{g.GetRepoUrl()},
+	})
+	refs, err := remote.List(&git.ListOptions{
+		Auth:         g.source.GitAuth(),
+		ProxyOptions: transport.ProxyOptions{URL: g.httpsProxy},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			if ref.Type() == plumbing.HashReference {
+				return ref.Hash().String(), nil
+			}
+			target := ref.Target()
+			for _, r := range refs {
+				if r.Name() == target {
+					return r.Hash().String(), nil
+				}
+			}
+		}
+	}
+	return "", fmt.Errorf("远程仓库%s没有HEAD", g.repo)
+}
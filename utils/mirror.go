@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GithubProxyAuto 表示自动选择最快的GitHub加速镜像, 用法: --github-proxy=auto
+const GithubProxyAuto = "auto"
+
+// 每个镜像测速时最多下载的字节数
+const probeMaxBytes = 1 << 20
+
+// DefaultGithubMirrors 是未配置镜像时参与测速的GitHub加速镜像
+var DefaultGithubMirrors = []string{
+	"https://ghproxy.net/",
+	"https://gh-proxy.com/",
+	"https://ghfast.top/",
+	"https://mirror.ghproxy.com/",
+}
+
+// DefaultProbeURL 是默认的测速文件
+const DefaultProbeURL = "https://github.com/RubyMetric/chsrc/releases/download/v0.1.8/chsrc-x64-linux"
+
+// MirrorProbeOptions 控制镜像测速
+type MirrorProbeOptions struct {
+	// 参与测速的镜像, 直连总是参与测速
+	Mirrors []string
+	// 测速下载的文件, 需要是 github.com 上的地址
+	URL string
+	// 每个镜像的超时时间
+	Timeout time.Duration
+	// 测速结果的缓存文件及有效期
+	CacheFile string
+	TTL       time.Duration
+}
+
+// MirrorResult 是一个镜像的测速结果
+type MirrorResult struct {
+	// 为空表示直连
+	Mirror string `json:"mirror"`
+	// 从发出请求到收到响应头的耗时
+	Latency time.Duration `json:"latency"`
+	// 下载速度, 字节/秒
+	Speed float64 `json:"speed"`
+	Error string  `json:"error,omitempty"`
+}
+
+// Name 返回镜像的显示名称
+func (r *MirrorResult) Name() string {
+	return sourceName(r.Mirror)
+}
+
+// mirrorCache 是保存在缓存文件中的测速结果
+type mirrorCache struct {
+	ProbedAt time.Time       `json:"probed_at"`
+	Results  []*MirrorResult `json:"results"`
+}
+
+var (
+	probeOptions = MirrorProbeOptions{
+		Mirrors: DefaultGithubMirrors,
+		URL:     DefaultProbeURL,
+		Timeout: 10 * time.Second,
+		TTL:     6 * time.Hour,
+	}
+	// 同一进程中只测速一次, 所有任务共用结果
+	autoProxyOnce sync.Once
+	autoProxy     string
+)
+
+// SetMirrorProbeOptions 设置镜像测速的参数
+func SetMirrorProbeOptions(options MirrorProbeOptions) {
+	probeOptions = options
+}
+
+// ProbeMirrors 同时对直连和所有镜像测速, 返回按速度从快到慢排序的结果, 失败的镜像排在最后
+func ProbeMirrors(httpsProxy string, logger *logrus.Logger) []*MirrorResult {
+	candidates := append([]string{""}, probeOptions.Mirrors...)
+	results := make([]*MirrorResult, len(candidates))
+	var wg sync.WaitGroup
+	for i, mirror := range candidates {
+		wg.Add(1)
+		go func(i int, mirror string) {
+			defer wg.Done()
+			results[i] = probeMirror(mirror, httpsProxy)
+			logger.Debugf("镜像%s测速完成", sourceName(mirror))
+		}(i, mirror)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if (results[i].Error == "") != (results[j].Error == "") {
+			return results[i].Error == ""
+		}
+		return results[i].Speed > results[j].Speed
+	})
+	if probeOptions.CacheFile != "" {
+		if err := saveMirrorCache(probeOptions.CacheFile, results); err != nil {
+			logger.Warnf("保存镜像测速结果失败:%s", err)
+		}
+	}
+	return results
+}
+
+func probeMirror(mirror, httpsProxy string) *MirrorResult {
+	result := &MirrorResult{Mirror: mirror}
+	client := generateHttpClient(httpsProxy)
+	client.Timeout = probeOptions.Timeout
+
+	start := time.Now()
+	resp, err := client.Get(sourceUrl(mirror, probeOptions.URL))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.Latency = time.Since(start)
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("响应状态码: %d", resp.StatusCode)
+		return result
+	}
+
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, probeMaxBytes))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		result.Speed = float64(n) / elapsed
+	}
+	return result
+}
+
+// ResolveGithubProxy 将 --github-proxy=auto 解析为测速最快的镜像, 直连最快时返回空字符串。
+// 优先使用有效期内的测速结果, 同一进程中只测速一次
+func ResolveGithubProxy(githubProxy, httpsProxy string, logger *logrus.Logger) string {
+	if githubProxy != GithubProxyAuto {
+		return githubProxy
+	}
+	autoProxyOnce.Do(func() {
+		results, ok := loadMirrorCache(probeOptions.CacheFile, probeOptions.TTL)
+		if ok {
+			logger.Debugf("使用缓存的镜像测速结果:%s", probeOptions.CacheFile)
+		} else {
+			logger.Infof("开始测试GitHub镜像速度...")
+			results = ProbeMirrors(httpsProxy, logger)
+		}
+		if len(results) == 0 || results[0].Error != "" {
+			logger.Warnf("所有GitHub镜像测速均失败, 使用直连")
+			return
+		}
+		autoProxy = results[0].Mirror
+		logger.Infof("自动选择GitHub下载源: %s", results[0].Name())
+	})
+	return autoProxy
+}
+
+func saveMirrorCache(path string, results []*MirrorResult) error {
+	content, err := json.MarshalIndent(&mirrorCache{ProbedAt: time.Now(), Results: results}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
+
+func loadMirrorCache(path string, ttl time.Duration) ([]*MirrorResult, bool) {
+	if path == "" {
+		return nil, false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	cache := &mirrorCache{}
+	if err := json.Unmarshal(content, cache); err != nil || time.Since(cache.ProbedAt) > ttl {
+		return nil, false
+	}
+	return cache.Results, true
+}
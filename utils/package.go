@@ -2,7 +2,10 @@ package utils
 
 import (
 	"fmt"
-	"os/exec"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -12,16 +15,44 @@ type Installer interface {
 	GetIsRoot() bool
 	InputSudoPasswd() error
 	GetPackageManager() string
+	PackageName(name string) string
 	Install(packages []string) error
 	Unintstall(packages []string) error
 	CheckUnInstall(name, command string) error
 	GetIsRequiresSudo() bool
 	CheckInstall(name, command string) error
+	MissingPackages(packages []SystemPackage) []string
+	IsPackageInstalled(name string) bool
+	InstalledVersion(name string) (string, error)
+	CandidateVersion(name string) (string, error)
 }
 
+// SystemPackage 是应用需要的一个系统软件包
+type SystemPackage struct {
+	// 逻辑名称, 安装时换成发行版的软件包名
+	Name string
+	// 用于判断是否已安装的命令, 为空时查询包管理器
+	Command string
+}
+
+// packageMu 串行化包管理器的安装和卸载, 并发任务同时调用apt-get等命令会因为锁文件被占用而失败
+var packageMu sync.Mutex
+
+// refreshedIndexes 记录本次运行中已经刷新过索引的包管理器, 由packageMu保护
+var refreshedIndexes = make(map[string]bool)
+
 // BaseInstaller struct, holds common methods and properties for all installers.
 type BaseInstaller struct {
 	packageManager string
+	// 发行版ID, 用于选择软件包名
+	distro string
+	// 非交互式的安装和卸载命令, 软件包名追加在命令后面
+	installCmd   string
+	uninstallCmd string
+	// 刷新软件源索引的命令, 每次运行只在第一次安装前执行一次, 为空时不刷新
+	refreshCmd string
+	// 安装时软件包名的前缀, 例如nix-env的频道 nixpkgs.
+	installPrefix  string
 	isRequiresSudo bool
 	isRoot         bool
 	logger         *logrus.Logger
@@ -31,6 +62,15 @@ func (bi *BaseInstaller) GetPackageManager() string {
 	return bi.packageManager
 }
 
+// PackageName 返回逻辑名称name在当前发行版上的软件包名
+func (bi *BaseInstaller) PackageName(name string) string {
+	return resolvePackageName(name, bi.distro, bi.packageManager)
+}
+
+func (bi *BaseInstaller) setDistro(distro string) {
+	bi.distro = distro
+}
+
 func (bi *BaseInstaller) GetIsRoot() bool {
 	return bi.isRoot
 }
@@ -39,40 +79,59 @@ func (bi *BaseInstaller) GetIsRequiresSudo() bool {
 	return bi.isRequiresSudo
 }
 
+// InputSudoPasswd 验证当前用户可以提权, 需要时在终端询问密码
 func (bi *BaseInstaller) InputSudoPasswd() error {
-	cmd := exec.Command("sudo", "-v")
-	return cmd.Run()
+	return ValidatePrivilege(bi.isRoot, bi.logger)
 }
 
-func (bi *BaseInstaller) Install(packages []string) error {
-	cmdStr := fmt.Sprintf("%s install -y", bi.packageManager)
+// packageCmd 生成对软件包执行cmd的命令, packages为逻辑名称, prefix加在软件包名前面
+func (bi *BaseInstaller) packageCmd(cmd, prefix string, packages []string) string {
+	cmdStr := cmd
 	for _, pkg := range packages {
-		cmdStr += fmt.Sprintf(" %s", pkg)
+		cmdStr += fmt.Sprintf(" %s%s", prefix, bi.PackageName(pkg))
 	}
-	cmdStr = GenerateCmd(cmdStr, true, bi.isRoot)
-
-	return ExecCmd(cmdStr, bi.logger)
+	return GenerateCmd(cmdStr, bi.isRequiresSudo, bi.isRoot)
 }
 
-func (bi *BaseInstaller) Unintstall(packages []string) error {
-	cmdStr := fmt.Sprintf("%s uninstall -y", bi.packageManager)
-	for _, pkg := range packages {
-		cmdStr += fmt.Sprintf(" %s", pkg)
+// refreshIndex 刷新软件源索引, 新建的容器中索引通常为空或已过期。调用者需要持有packageMu
+func (bi *BaseInstaller) refreshIndex() {
+	if bi.refreshCmd == "" || refreshedIndexes[bi.packageManager] {
+		return
 	}
+	refreshedIndexes[bi.packageManager] = true
+	bi.logger.Infof("刷新%s的软件源索引...", bi.packageManager)
+	// 个别软件源不可用时索引刷新会失败, 但其余软件源中的软件包仍然可以安装
+	if err := ExecCmd(GenerateCmd(bi.refreshCmd, bi.isRequiresSudo, bi.isRoot), bi.logger); err != nil {
+		bi.logger.Warnf("刷新%s的软件源索引失败: %s", bi.packageManager, err)
+	}
+}
+
+func (bi *BaseInstaller) Install(packages []string) error {
+	packageMu.Lock()
+	defer packageMu.Unlock()
+
+	bi.refreshIndex()
+
+	return ExecCmd(bi.packageCmd(bi.installCmd, bi.installPrefix, packages), bi.logger)
+}
 
-	cmdStr = GenerateCmd(cmdStr, true, bi.isRoot)
+func (bi *BaseInstaller) Unintstall(packages []string) error {
+	packageMu.Lock()
+	defer packageMu.Unlock()
 
-	return ExecCmd(cmdStr, bi.logger)
+	return ExecCmd(bi.packageCmd(bi.uninstallCmd, "", packages), bi.logger)
 }
 
 func (bi *BaseInstaller) CheckInstall(name, command string) error {
 	if !IsCommandAvailable(command) {
 		bi.logger.Warnf("检测%s不存在,需要先安装%s", name, name)
-		if bi.isRequiresSudo && !bi.isRoot {
-			bi.logger.Infof("当前用户不是root用户,且需要sudo权限,请输入sudo密码")
-			bi.InputSudoPasswd()
+		if bi.isRequiresSudo {
+			if err := bi.InputSudoPasswd(); err != nil {
+				bi.logger.Errorf("%s安装失败,%s", name, err)
+				return err
+			}
 		}
-		bi.logger.Infof("开始使用%s安装%s...", bi.packageManager, name)
+		bi.logger.Infof("开始使用%s安装%s...", bi.packageManager, bi.PackageName(name))
 		if err := bi.Install([]string{name}); err != nil {
 			bi.logger.Errorf("%s安装失败,%s", name, err)
 			return err
@@ -85,17 +144,39 @@ func (bi *BaseInstaller) CheckInstall(name, command string) error {
 	return nil
 }
 
+// MissingPackages 返回packages中尚未安装的软件包的逻辑名称, 重复的软件包只返回一次
+func (bi *BaseInstaller) MissingPackages(packages []SystemPackage) []string {
+	var missing []string
+	seen := make(map[string]bool, len(packages))
+	for _, pkg := range packages {
+		if seen[pkg.Name] {
+			continue
+		}
+		seen[pkg.Name] = true
+		if pkg.Command != "" && IsCommandAvailable(pkg.Command) {
+			continue
+		}
+		if pkg.Command == "" && bi.IsPackageInstalled(pkg.Name) {
+			continue
+		}
+		missing = append(missing, pkg.Name)
+	}
+	return missing
+}
+
 func (bi *BaseInstaller) CheckUnInstall(name, command string) error {
 	if !IsCommandAvailable(command) {
 		bi.logger.Warnf("检测%s不存在,不需要卸载", name)
 		return nil
 	}
 	bi.logger.Infof("检测%s存在", name)
-	if bi.isRequiresSudo && !bi.isRoot {
-		bi.logger.Infof("当前用户不是root用户,且需要sudo权限,请输入sudo密码")
-		bi.InputSudoPasswd()
+	if bi.isRequiresSudo {
+		if err := bi.InputSudoPasswd(); err != nil {
+			bi.logger.Errorf("%s卸载失败,%s", name, err)
+			return err
+		}
 	}
-	bi.logger.Infof("开始使用%s卸载%s...", bi.packageManager, name)
+	bi.logger.Infof("开始使用%s卸载%s...", bi.packageManager, bi.PackageName(name))
 	if err := bi.Unintstall([]string{name}); err != nil {
 		bi.logger.Errorf("%s卸载失败,%s", name, err)
 		return err
@@ -110,23 +191,15 @@ type AptInstaller struct {
 	BaseInstaller
 }
 
-func (apt *AptInstaller) Unintstall(packages []string) error {
-	cmdStr := fmt.Sprintf("%s remove -y --purge", apt.packageManager)
-	for _, pkg := range packages {
-		cmdStr += fmt.Sprintf(" %s", pkg)
-	}
-	apt.logger.Infof(cmdStr)
-
-	cmdStr = GenerateCmd(cmdStr, true, apt.isRoot)
-
-	return ExecCmd(cmdStr, apt.logger)
-}
-
 // NewAptInstaller creates a new AptInstaller instance.
 func NewAptInstaller(isRoot bool, logger *logrus.Logger) *AptInstaller {
 	return &AptInstaller{
 		BaseInstaller{
 			packageManager: "apt-get",
+			// 避免tzdata等软件包在安装时等待输入, 使用env以便doas也能设置环境变量
+			installCmd:     "env DEBIAN_FRONTEND=noninteractive apt-get install -y",
+			uninstallCmd:   "env DEBIAN_FRONTEND=noninteractive apt-get remove -y --purge",
+			refreshCmd:     "apt-get update",
 			isRequiresSudo: true,
 			isRoot:         isRoot,
 			logger:         logger,
@@ -144,6 +217,29 @@ func NewYumInstaller(isRoot bool, logger *logrus.Logger) *YumInstaller {
 	return &YumInstaller{
 		BaseInstaller{
 			packageManager: "yum",
+			installCmd:     "yum install -y",
+			uninstallCmd:   "yum remove -y",
+			refreshCmd:     "yum makecache",
+			isRequiresSudo: true,
+			isRoot:         isRoot,
+			logger:         logger,
+		},
+	}
+}
+
+// DnfInstaller struct for handling dnf specific installation.
+type DnfInstaller struct {
+	BaseInstaller
+}
+
+// NewDnfInstaller creates a new DnfInstaller instance.
+func NewDnfInstaller(isRoot bool, logger *logrus.Logger) *DnfInstaller {
+	return &DnfInstaller{
+		BaseInstaller{
+			packageManager: "dnf",
+			installCmd:     "dnf install -y",
+			uninstallCmd:   "dnf remove -y",
+			refreshCmd:     "dnf makecache",
 			isRequiresSudo: true,
 			isRoot:         isRoot,
 			logger:         logger,
@@ -151,6 +247,89 @@ func NewYumInstaller(isRoot bool, logger *logrus.Logger) *YumInstaller {
 	}
 }
 
+// PacmanInstaller struct for handling pacman specific installation.
+type PacmanInstaller struct {
+	BaseInstaller
+}
+
+// NewPacmanInstaller creates a new PacmanInstaller instance.
+func NewPacmanInstaller(isRoot bool, logger *logrus.Logger) *PacmanInstaller {
+	return &PacmanInstaller{
+		BaseInstaller{
+			packageManager: "pacman",
+			// --needed 跳过已安装的软件包, -Rns 同时删除不再需要的依赖和配置文件
+			installCmd:   "pacman -S --noconfirm --needed",
+			uninstallCmd: "pacman -Rns --noconfirm",
+			// 只刷新索引而不升级系统(-Sy)会导致部分升级, 不自动刷新
+			isRequiresSudo: true,
+			isRoot:         isRoot,
+			logger:         logger,
+		},
+	}
+}
+
+// ZypperInstaller struct for handling zypper specific installation.
+type ZypperInstaller struct {
+	BaseInstaller
+}
+
+// NewZypperInstaller creates a new ZypperInstaller instance.
+func NewZypperInstaller(isRoot bool, logger *logrus.Logger) *ZypperInstaller {
+	return &ZypperInstaller{
+		BaseInstaller{
+			packageManager: "zypper",
+			installCmd:     "zypper --non-interactive install",
+			uninstallCmd:   "zypper --non-interactive remove",
+			refreshCmd:     "zypper --non-interactive refresh",
+			isRequiresSudo: true,
+			isRoot:         isRoot,
+			logger:         logger,
+		},
+	}
+}
+
+// ApkInstaller struct for handling Alpine apk specific installation.
+type ApkInstaller struct {
+	BaseInstaller
+}
+
+// NewApkInstaller creates a new ApkInstaller instance.
+func NewApkInstaller(isRoot bool, logger *logrus.Logger) *ApkInstaller {
+	return &ApkInstaller{
+		BaseInstaller{
+			packageManager: "apk",
+			// apk 默认不询问, --no-cache 每次安装时获取最新的索引且不在容器中保留缓存, 不需要单独刷新
+			installCmd:     "apk add --no-cache",
+			uninstallCmd:   "apk del",
+			isRequiresSudo: true,
+			isRoot:         isRoot,
+			logger:         logger,
+		},
+	}
+}
+
+// NixInstaller struct for handling nix-env specific installation.
+type NixInstaller struct {
+	BaseInstaller
+}
+
+// NewNixInstaller creates a new NixInstaller instance. 软件包安装到当前用户的profile, 不需要sudo;
+// channel 为软件包所在的频道, NixOS上为 nixos, 其他系统上为 nixpkgs
+func NewNixInstaller(channel string, isRoot bool, logger *logrus.Logger) *NixInstaller {
+	return &NixInstaller{
+		BaseInstaller{
+			packageManager: "nix-env",
+			// 按属性路径安装比按名称安装快得多, 也不会选错软件包
+			installCmd:     "nix-env -iA",
+			uninstallCmd:   "nix-env -e",
+			installPrefix:  channel + ".",
+			isRequiresSudo: false,
+			isRoot:         isRoot,
+			logger:         logger,
+		},
+	}
+}
+
 // BrewInstaller struct for handling Homebrew specific installation.
 type BrewInstaller struct {
 	BaseInstaller
@@ -161,6 +340,9 @@ func NewBrewInstaller(isRoot bool, logger *logrus.Logger) *BrewInstaller {
 	return &BrewInstaller{
 		BaseInstaller{
 			packageManager: "brew",
+			// brew 不询问确认, 也不支持 -y
+			installCmd:     "brew install",
+			uninstallCmd:   "brew uninstall",
 			isRequiresSudo: false,
 			isRoot:         isRoot,
 			logger:         logger,
@@ -178,6 +360,8 @@ func NewPortInstaller(isRoot bool, logger *logrus.Logger) *PortInstaller {
 	return &PortInstaller{
 		BaseInstaller{
 			packageManager: "port",
+			installCmd:     "port -N install",
+			uninstallCmd:   "port -N uninstall",
 			isRequiresSudo: true,
 			isRoot:         isRoot,
 			logger:         logger,
@@ -185,18 +369,124 @@ func NewPortInstaller(isRoot bool, logger *logrus.Logger) *PortInstaller {
 	}
 }
 
-// GetInstaller returns an appropriate installer instance based on available package manager.
-func GetInstaller(isRoot bool, logger *logrus.Logger) (Installer, error) {
-	if IsCommandAvailable("apt-get") {
-		return NewAptInstaller(isRoot, logger), nil
-	} else if IsCommandAvailable("yum") {
-		return NewYumInstaller(isRoot, logger), nil
-	} else if IsCommandAvailable("brew") {
+// osReleasePath 是记录Linux发行版信息的文件
+const osReleasePath = "/etc/os-release"
+
+// distroPackageManagers 是各发行版(os-release中的ID和ID_LIKE)使用的包管理器, 按优先级排列
+var distroPackageManagers = map[string][]string{
+	"debian":    {"apt-get"},
+	"ubuntu":    {"apt-get"},
+	"fedora":    {"dnf", "yum"},
+	"rhel":      {"dnf", "yum"},
+	"centos":    {"dnf", "yum"},
+	"rocky":     {"dnf", "yum"},
+	"almalinux": {"dnf", "yum"},
+	"amzn":      {"dnf", "yum"},
+	"arch":      {"pacman"},
+	"manjaro":   {"pacman"},
+	"opensuse":  {"zypper"},
+	"suse":      {"zypper"},
+	"sles":      {"zypper"},
+	"alpine":    {"apk"},
+	"nixos":     {"nix-env"},
+}
+
+// fallbackPackageManagers 是无法识别发行版时在PATH中依次查找的包管理器。
+// nix-env 可以和系统的包管理器共存, 放在最后
+var fallbackPackageManagers = []string{"apt-get", "dnf", "yum", "pacman", "zypper", "apk", "brew", "port", "nix-env"}
+
+// parseOSRelease 解析os-release格式的内容, 值两边的引号会被去掉
+func parseOSRelease(content string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, `"'`)
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// detectPackageManager 根据os-release中的ID和ID_LIKE选择包管理器, 发行版的包管理器不可用时按PATH查找
+func detectPackageManager(osRelease map[string]string, available func(string) bool) string {
+	ids := strings.Fields(osRelease["ID"] + " " + osRelease["ID_LIKE"])
+	for _, id := range ids {
+		// openSUSE的ID是 opensuse-leap、opensuse-tumbleweed 等
+		if strings.HasPrefix(id, "opensuse") {
+			id = "opensuse"
+		}
+		for _, pm := range distroPackageManagers[id] {
+			if available(pm) {
+				return pm
+			}
+		}
+	}
+	for _, pm := range fallbackPackageManagers {
+		if available(pm) {
+			return pm
+		}
+	}
+	return ""
+}
+
+// newInstaller 返回包管理器对应的安装器
+func newInstaller(packageManager string, osRelease map[string]string, isRoot bool, logger *logrus.Logger) Installer {
+	var installer interface {
+		Installer
+		setDistro(distro string)
+	}
+	switch packageManager {
+	case "apt-get":
+		installer = NewAptInstaller(isRoot, logger)
+	case "dnf":
+		installer = NewDnfInstaller(isRoot, logger)
+	case "yum":
+		installer = NewYumInstaller(isRoot, logger)
+	case "pacman":
+		installer = NewPacmanInstaller(isRoot, logger)
+	case "zypper":
+		installer = NewZypperInstaller(isRoot, logger)
+	case "apk":
+		installer = NewApkInstaller(isRoot, logger)
+	case "brew":
 		// Homebrew does not require sudo
-		return NewBrewInstaller(isRoot, logger), nil
-	} else if IsCommandAvailable("port") {
-		return NewPortInstaller(isRoot, logger), nil
-	} else {
+		installer = NewBrewInstaller(isRoot, logger)
+	case "port":
+		installer = NewPortInstaller(isRoot, logger)
+	case "nix-env":
+		if osRelease["ID"] == "nixos" {
+			installer = NewNixInstaller("nixos", isRoot, logger)
+		} else {
+			installer = NewNixInstaller("nixpkgs", isRoot, logger)
+		}
+	default:
+		return nil
+	}
+	installer.setDistro(osRelease["ID"])
+	return installer
+}
+
+// GetInstaller returns an appropriate installer instance based on the distribution in /etc/os-release,
+// falling back to the first package manager found in PATH.
+func GetInstaller(isRoot bool, logger *logrus.Logger) (Installer, error) {
+	// macOS等系统没有os-release, 直接按PATH查找
+	var osRelease map[string]string
+	if content, err := os.ReadFile(osReleasePath); err == nil {
+		osRelease = parseOSRelease(string(content))
+	}
+	packageManager := detectPackageManager(osRelease, IsCommandAvailable)
+	if packageManager == "" {
 		return nil, fmt.Errorf("找不到适合的包管理器")
 	}
+	return newInstaller(packageManager, osRelease, isRoot, logger), nil
 }
@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	content := `# comment
+NAME="Rocky Linux"
+ID="rocky"
+ID_LIKE="rhel centos fedora"
+VERSION_ID=9.3
+
+PRETTY_NAME='Rocky Linux 9.3 (Blue Onyx)'
+`
+	want := map[string]string{
+		"NAME":        "Rocky Linux",
+		"ID":          "rocky",
+		"ID_LIKE":     "rhel centos fedora",
+		"VERSION_ID":  "9.3",
+		"PRETTY_NAME": "Rocky Linux 9.3 (Blue Onyx)",
+	}
+	if got := parseOSRelease(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOSRelease() = %v, want %v", got, want)
+	}
+}
+
+func TestDetectPackageManager(t *testing.T) {
+	tests := []struct {
+		name      string
+		osRelease map[string]string
+		available []string
+		want      string
+	}{
+		{name: "Fedora优先使用dnf", osRelease: map[string]string{"ID": "fedora"}, available: []string{"yum", "dnf"}, want: "dnf"},
+		{name: "CentOS 7没有dnf", osRelease: map[string]string{"ID": "centos", "ID_LIKE": "rhel fedora"}, available: []string{"yum"}, want: "yum"},
+		{name: "按ID_LIKE识别", osRelease: map[string]string{"ID": "linuxmint", "ID_LIKE": "ubuntu debian"}, available: []string{"apt-get"}, want: "apt-get"},
+		{name: "Arch", osRelease: map[string]string{"ID": "arch"}, available: []string{"pacman"}, want: "pacman"},
+		{name: "openSUSE", osRelease: map[string]string{"ID": "opensuse-tumbleweed", "ID_LIKE": "opensuse suse"}, available: []string{"zypper"}, want: "zypper"},
+		{name: "Alpine", osRelease: map[string]string{"ID": "alpine"}, available: []string{"apk"}, want: "apk"},
+		{name: "NixOS", osRelease: map[string]string{"ID": "nixos"}, available: []string{"nix-env"}, want: "nix-env"},
+		{name: "发行版优先于PATH顺序", osRelease: map[string]string{"ID": "arch"}, available: []string{"apt-get", "pacman"}, want: "pacman"},
+		{name: "Debian上同时装有nix", osRelease: map[string]string{"ID": "debian"}, available: []string{"nix-env", "apt-get"}, want: "apt-get"},
+		{name: "没有os-release", available: []string{"port", "brew"}, want: "brew"},
+		{name: "未知发行版按PATH查找", osRelease: map[string]string{"ID": "unknown"}, available: []string{"zypper"}, want: "zypper"},
+		{name: "没有包管理器", osRelease: map[string]string{"ID": "debian"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			available := func(cmd string) bool {
+				for _, name := range tt.available {
+					if name == cmd {
+						return true
+					}
+				}
+				return false
+			}
+			if got := detectPackageManager(tt.osRelease, available); got != tt.want {
+				t.Errorf("detectPackageManager() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// skipIndexRefresh 让测试中的安装命令不包含刷新索引的命令
+func skipIndexRefresh(t *testing.T) {
+	t.Helper()
+	refreshed := refreshedIndexes
+	refreshedIndexes = make(map[string]bool)
+	for _, pm := range fallbackPackageManagers {
+		refreshedIndexes[pm] = true
+	}
+	t.Cleanup(func() { refreshedIndexes = refreshed })
+}
+
+func TestInstallerCommands(t *testing.T) {
+	skipIndexRefresh(t)
+	usePrivilegeTool(t, "sudo")
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	tests := []struct {
+		packageManager string
+		osRelease      map[string]string
+		isRoot         bool
+		wantInstall    string
+		wantUninstall  string
+	}{
+		{packageManager: "apt-get", wantInstall: "sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y zsh git", wantUninstall: "sudo env DEBIAN_FRONTEND=noninteractive apt-get remove -y --purge zsh git"},
+		{packageManager: "dnf", isRoot: true, wantInstall: "dnf install -y zsh git", wantUninstall: "dnf remove -y zsh git"},
+		{packageManager: "yum", wantInstall: "sudo yum install -y zsh git", wantUninstall: "sudo yum remove -y zsh git"},
+		{packageManager: "pacman", wantInstall: "sudo pacman -S --noconfirm --needed zsh git", wantUninstall: "sudo pacman -Rns --noconfirm zsh git"},
+		{packageManager: "zypper", wantInstall: "sudo zypper --non-interactive install zsh git", wantUninstall: "sudo zypper --non-interactive remove zsh git"},
+		{packageManager: "apk", isRoot: true, wantInstall: "apk add --no-cache zsh git", wantUninstall: "apk del zsh git"},
+		{packageManager: "brew", wantInstall: "brew install zsh git", wantUninstall: "brew uninstall zsh git"},
+		{packageManager: "port", wantInstall: "sudo port -N install zsh git", wantUninstall: "sudo port -N uninstall zsh git"},
+		{packageManager: "nix-env", wantInstall: "nix-env -iA nixpkgs.zsh nixpkgs.git", wantUninstall: "nix-env -e zsh git"},
+		{packageManager: "nix-env", osRelease: map[string]string{"ID": "nixos"}, wantInstall: "nix-env -iA nixos.zsh nixos.git", wantUninstall: "nix-env -e zsh git"},
+	}
+	packages := []string{"zsh", "git"}
+	for _, tt := range tests {
+		installer := newInstaller(tt.packageManager, tt.osRelease, tt.isRoot, logger)
+		if installer.GetPackageManager() != tt.packageManager {
+			t.Fatalf("newInstaller(%s) = %s", tt.packageManager, installer.GetPackageManager())
+		}
+
+		SetDryRun(true)
+		recorded := len(GetPlan())
+		if err := installer.Install(packages); err != nil {
+			t.Fatal(err)
+		}
+		if err := installer.Unintstall(packages); err != nil {
+			t.Fatal(err)
+		}
+		steps := GetPlan()[recorded:]
+		SetDryRun(false)
+
+		if len(steps) != 2 || steps[0].Target != tt.wantInstall || steps[1].Target != tt.wantUninstall {
+			t.Errorf("%s: 命令 = %+v, want %q, %q", tt.packageManager, steps, tt.wantInstall, tt.wantUninstall)
+		}
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	skipIndexRefresh(t)
+	SetPackageNames(map[string]map[string]string{
+		"fd":  {"ubuntu": "fd"},
+		"vim": {"dnf": "vim-minimal"},
+	})
+	defer SetPackageNames(nil)
+
+	tests := []struct {
+		name           string
+		distro         string
+		packageManager string
+		want           string
+	}{
+		{name: "zsh", distro: "debian", packageManager: "apt-get", want: "zsh"},
+		{name: "fd", distro: "debian", packageManager: "apt-get", want: "fd-find"},
+		{name: "fd", distro: "arch", packageManager: "pacman", want: "fd"},
+		{name: "vim", distro: "rocky", packageManager: "yum", want: "vim-enhanced"},
+		{name: "pip", distro: "alpine", packageManager: "apk", want: "py3-pip"},
+		// 用户配置优先于内置的软件包名
+		{name: "fd", distro: "ubuntu", packageManager: "apt-get", want: "fd"},
+		{name: "vim", distro: "fedora", packageManager: "dnf", want: "vim-minimal"},
+	}
+	for _, tt := range tests {
+		if got := resolvePackageName(tt.name, tt.distro, tt.packageManager); got != tt.want {
+			t.Errorf("resolvePackageName(%s, %s, %s) = %s, want %s", tt.name, tt.distro, tt.packageManager, got, tt.want)
+		}
+	}
+
+	// 安装命令使用发行版的软件包名
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	installer := newInstaller("dnf", map[string]string{"ID": "rhel"}, true, logger)
+	SetDryRun(true)
+	recorded := len(GetPlan())
+	if err := installer.Install([]string{"fd", "pip"}); err != nil {
+		t.Fatal(err)
+	}
+	steps := GetPlan()[recorded:]
+	SetDryRun(false)
+	if want := "dnf install -y fd-find python3-pip"; len(steps) != 1 || steps[0].Target != want {
+		t.Errorf("Install() = %+v, want %q", steps, want)
+	}
+}
+
+func TestInstallMissingPackages(t *testing.T) {
+	refreshed := refreshedIndexes
+	refreshedIndexes = make(map[string]bool)
+	defer func() { refreshedIndexes = refreshed }()
+
+	usePrivilegeTool(t, "sudo")
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	installer := newInstaller("apt-get", map[string]string{"ID": "debian"}, false, logger)
+
+	SetDryRun(true)
+	defer SetDryRun(false)
+	recorded := len(GetPlan())
+	// sh 总是存在, 重复声明的软件包只安装一次
+	packages := []SystemPackage{
+		{Name: "sh", Command: "sh"},
+		{Name: "envsetup-test-zsh", Command: "envsetup-test-zsh"},
+		{Name: "fd", Command: "envsetup-test-fd"},
+		{Name: "envsetup-test-zsh", Command: "envsetup-test-zsh"},
+	}
+	missing := installer.MissingPackages(packages)
+	if want := []string{"envsetup-test-zsh", "fd"}; !reflect.DeepEqual(missing, want) {
+		t.Fatalf("MissingPackages() = %q, want %q", missing, want)
+	}
+	if err := installer.Install(missing); err != nil {
+		t.Fatal(err)
+	}
+	// 第二次安装不再刷新索引
+	if err := installer.Install([]string{"git"}); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, step := range GetPlan()[recorded:] {
+		got = append(got, step.Target)
+	}
+	want := []string{
+		"sudo apt-get update",
+		"sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y envsetup-test-zsh fd-find",
+		"sudo env DEBIAN_FRONTEND=noninteractive apt-get install -y git",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("命令 = %q, want %q", got, want)
+	}
+}
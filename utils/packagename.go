@@ -0,0 +1,59 @@
+package utils
+
+// defaultPackageNames 是与逻辑名称不同的软件包名, 键为逻辑名称,
+// 值的键为发行版ID(/etc/os-release中的ID)或包管理器, 发行版ID优先
+var defaultPackageNames = map[string]map[string]string{
+	"fd": {
+		"apt-get": "fd-find",
+		"dnf":     "fd-find",
+		"yum":     "fd-find",
+	},
+	"vim": {
+		"dnf": "vim-enhanced",
+		"yum": "vim-enhanced",
+	},
+	"pip": {
+		"apt-get": "python3-pip",
+		"dnf":     "python3-pip",
+		"yum":     "python3-pip",
+		"zypper":  "python3-pip",
+		"pacman":  "python-pip",
+		"apk":     "py3-pip",
+		"brew":    "python",
+		"nix-env": "python3Packages.pip",
+	},
+	"python3": {
+		"pacman":  "python",
+		"brew":    "python",
+		"nix-env": "python3",
+	},
+}
+
+var packageNames map[string]map[string]string
+
+// SetPackageNames 设置用户配置的软件包名, 格式与 defaultPackageNames 相同, 优先于内置的软件包名
+func SetPackageNames(names map[string]map[string]string) {
+	packageNames = names
+}
+
+// lookupPackageName 在names中查找逻辑名称name在发行版distro或包管理器packageManager上的软件包名
+func lookupPackageName(names map[string]map[string]string, name, distro, packageManager string) (string, bool) {
+	if distro != "" {
+		if pkg, ok := names[name][distro]; ok {
+			return pkg, true
+		}
+	}
+	pkg, ok := names[name][packageManager]
+	return pkg, ok
+}
+
+// resolvePackageName 返回逻辑名称name对应的软件包名, 没有配置时与逻辑名称相同
+func resolvePackageName(name, distro, packageManager string) string {
+	if pkg, ok := lookupPackageName(packageNames, name, distro, packageManager); ok {
+		return pkg
+	}
+	if pkg, ok := lookupPackageName(defaultPackageNames, name, distro, packageManager); ok {
+		return pkg
+	}
+	return name
+}
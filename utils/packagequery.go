@@ -0,0 +1,299 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// packageQuery 是包管理器查询软件包版本的命令, 命令中的 {package} 会替换为软件包名,
+// {attr} 会替换为安装时使用的名称 (例如nix-env的 nixpkgs.python3Packages.pip)。
+// parse 从命令的标准输出中提取版本号, 没有找到时返回空
+type packageQuery struct {
+	// resolve 在查询已安装版本前把安装时使用的名称转换为已安装的软件包名, 为空时直接使用软件包名
+	resolve        func(attr string) (string, error)
+	installed      string
+	parseInstalled func(pkg, output string) string
+	candidate      string
+	parseCandidate func(pkg, output string) string
+}
+
+// rpmInstalled 查询rpm系发行版上已安装的版本
+const rpmInstalled = "rpm -q --qf '%{VERSION}-%{RELEASE}\\n' {package}"
+
+var packageQueries = map[string]packageQuery{
+	"apt-get": {
+		installed:      "dpkg-query -W -f='${Status}\\t${Version}\\n' {package}",
+		parseInstalled: parseDpkgStatus,
+		candidate:      "apt-cache policy {package}",
+		parseCandidate: func(pkg, output string) string { return fieldValue(output, "Candidate") },
+	},
+	"dnf": {
+		installed:      rpmInstalled,
+		parseInstalled: lastLine,
+		candidate:      "dnf -q repoquery --latest-limit 1 --qf '%{version}-%{release}\\n' {package}",
+		parseCandidate: lastLine,
+	},
+	"yum": {
+		installed:      rpmInstalled,
+		parseInstalled: lastLine,
+		// 只列出软件源中比已安装版本新的软件包
+		candidate:      "yum -q list available {package}",
+		parseCandidate: parseYumList,
+	},
+	"zypper": {
+		installed:      rpmInstalled,
+		parseInstalled: lastLine,
+		candidate:      "zypper --non-interactive --quiet info {package}",
+		parseCandidate: func(pkg, output string) string { return fieldValue(output, "Version") },
+	},
+	"pacman": {
+		installed:      "pacman -Q {package}",
+		parseInstalled: parseNameVersion,
+		candidate:      "pacman -Si {package}",
+		parseCandidate: func(pkg, output string) string { return fieldValue(output, "Version") },
+	},
+	"apk": {
+		installed:      "apk list --installed {package}",
+		parseInstalled: parseApkList,
+		candidate:      "apk list {package}",
+		parseCandidate: parseApkList,
+	},
+	"brew": {
+		installed:      "brew list --versions {package}",
+		parseInstalled: parseNameVersion,
+		candidate:      "brew info --json=v2 {package}",
+		parseCandidate: parseBrewInfo,
+	},
+	"port": {
+		installed:      "port -q installed {package}",
+		parseInstalled: parsePortInstalled,
+		candidate:      "port -q info --version {package}",
+		parseCandidate: lastLine,
+	},
+	"nix-env": {
+		// nix-env -q 按derivation名称匹配, 需要先按属性路径查出名称
+		resolve:        resolveNixName,
+		installed:      "nix-env -q {package}",
+		parseInstalled: parseNixName,
+		candidate:      "nix-env -qaA {attr}",
+		parseCandidate: parseNixVersion,
+	},
+}
+
+// IsPackageInstalled 返回逻辑名称为name的软件包是否已安装, 查询失败时视为未安装
+func (bi *BaseInstaller) IsPackageInstalled(name string) bool {
+	version, err := bi.InstalledVersion(name)
+	if err != nil {
+		bi.logger.Debugf("查询%s是否安装失败: %s", name, err)
+	}
+	return version != ""
+}
+
+// InstalledVersion 返回逻辑名称为name的软件包已安装的版本, 未安装时返回空
+func (bi *BaseInstaller) InstalledVersion(name string) (string, error) {
+	query, ok := packageQueries[bi.packageManager]
+	if !ok {
+		return "", fmt.Errorf("%s不支持查询软件包", bi.packageManager)
+	}
+	pkg := bi.PackageName(name)
+	attr := bi.installPrefix + pkg
+	if query.resolve != nil {
+		resolved, err := query.resolve(attr)
+		if err != nil {
+			return "", err
+		}
+		pkg = resolved
+	}
+	output, err := queryPackage(query.installed, pkg, attr)
+	if err != nil {
+		// 软件包未安装时查询命令的退出码不为0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() != 127 {
+			return "", nil
+		}
+		return "", err
+	}
+	return query.parseInstalled(pkg, output), nil
+}
+
+// CandidateVersion 返回软件源中逻辑名称为name的软件包可以安装的版本, 软件源中没有时返回空
+func (bi *BaseInstaller) CandidateVersion(name string) (string, error) {
+	query, ok := packageQueries[bi.packageManager]
+	if !ok {
+		return "", fmt.Errorf("%s不支持查询软件包", bi.packageManager)
+	}
+	pkg := bi.PackageName(name)
+	output, err := queryPackage(query.candidate, pkg, bi.installPrefix+pkg)
+	if err != nil {
+		return "", fmt.Errorf("查询%s可安装的版本失败: %w", pkg, err)
+	}
+	return query.parseCandidate(pkg, output), nil
+}
+
+// queryPackage 执行只读的查询命令并返回标准输出, dry-run 模式下同样会执行
+func queryPackage(cmdTmpl, pkg, attr string) (string, error) {
+	var stdout bytes.Buffer
+	cmdStr := strings.NewReplacer("{package}", pkg, "{attr}", attr).Replace(cmdTmpl)
+	cmd := exec.Command("bash", "-c", cmdStr)
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	return strings.TrimSpace(stdout.String()), err
+}
+
+// fieldValue 返回 "key: value" 格式输出中key的值, 值为 (none) 时返回空
+func fieldValue(output, key string) string {
+	for _, line := range strings.Split(output, "\n") {
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(k) != key {
+			continue
+		}
+		v = strings.TrimSpace(v)
+		if v == "(none)" {
+			return ""
+		}
+		return v
+	}
+	return ""
+}
+
+func lastLine(pkg, output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// parseDpkgStatus 解析 "install ok installed\t5.9-4", 只有状态为installed时才算已安装
+func parseDpkgStatus(pkg, output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		status, version, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(status)
+		if ok && len(fields) == 3 && fields[2] == "installed" {
+			return strings.TrimSpace(version)
+		}
+	}
+	return ""
+}
+
+// parseNameVersion 解析 "zsh 5.9-5", 有多个版本时使用最后一个
+func parseNameVersion(pkg, output string) string {
+	fields := strings.Fields(strings.Split(output, "\n")[0])
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// parseYumList 解析 "zsh.x86_64  5.8-9.el9  baseos"
+func parseYumList(pkg, output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.HasPrefix(fields[0], pkg+".") {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// parseApkList 解析 "zsh-5.9-r4 x86_64 {zsh} (MIT) [installed]"
+func parseApkList(pkg, output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if version, ok := trimPackageName(fields[0], pkg); ok {
+			return version
+		}
+	}
+	return ""
+}
+
+// parseBrewInfo 解析 brew info --json=v2 中formula或cask的版本
+func parseBrewInfo(pkg, output string) string {
+	var info struct {
+		Formulae []struct {
+			Versions struct {
+				Stable string `json:"stable"`
+			} `json:"versions"`
+		} `json:"formulae"`
+		Casks []struct {
+			Version string `json:"version"`
+		} `json:"casks"`
+	}
+	if err := json.Unmarshal([]byte(output), &info); err != nil {
+		return ""
+	}
+	if len(info.Formulae) > 0 {
+		return info.Formulae[0].Versions.Stable
+	}
+	if len(info.Casks) > 0 {
+		return info.Casks[0].Version
+	}
+	return ""
+}
+
+// parsePortInstalled 解析 "  zsh @5.9_0 (active)", 优先使用已激活的版本
+func parsePortInstalled(pkg, output string) string {
+	version := ""
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "@") {
+			continue
+		}
+		version = strings.TrimPrefix(fields[1], "@")
+		if strings.Contains(line, "(active)") {
+			return version
+		}
+	}
+	return version
+}
+
+// parseNixName 解析 "zsh-5.9"
+func parseNixName(pkg, output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if version, ok := trimPackageName(strings.TrimSpace(line), pkg); ok {
+			return version
+		}
+	}
+	return ""
+}
+
+// parseNixVersion 解析按属性路径查询到的 "python3.11-pip-23.2.1"
+func parseNixVersion(pkg, output string) string {
+	_, version := splitNixName(strings.Split(output, "\n")[0])
+	return version
+}
+
+// resolveNixName 查询属性路径对应的derivation名称, 例如 nixpkgs.python3Packages.pip 对应 python3.11-pip
+func resolveNixName(attr string) (string, error) {
+	output, err := queryPackage("nix-env -qaA {attr}", "", attr)
+	if err != nil {
+		return "", fmt.Errorf("查询%s的软件包名失败: %w", attr, err)
+	}
+	name, _ := splitNixName(strings.Split(output, "\n")[0])
+	return name, nil
+}
+
+// splitNixName 按nix的规则拆分derivation名称和版本: 名称到第一个后面不是字母的 "-" 为止
+func splitNixName(drvName string) (string, string) {
+	drvName = strings.TrimSpace(drvName)
+	for i := 0; i+1 < len(drvName); i++ {
+		if drvName[i] == '-' && !unicode.IsLetter(rune(drvName[i+1])) {
+			return drvName[:i], drvName[i+1:]
+		}
+	}
+	return drvName, ""
+}
+
+// trimPackageName 从 "<软件包名>-<版本>" 中取出版本, 版本以数字开头, 以免 zsh-vcs-5.9 被当作zsh
+func trimPackageName(name, pkg string) (string, bool) {
+	version := strings.TrimPrefix(name, pkg+"-")
+	if version == name || version == "" || version[0] < '0' || version[0] > '9' {
+		return "", false
+	}
+	return version, true
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestParsePackageVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		parse  func(pkg, output string) string
+		output string
+		want   string
+	}{
+		{name: "dpkg已安装", parse: parseDpkgStatus, output: "install ok installed\t5.9-4+b2", want: "5.9-4+b2"},
+		{name: "dpkg已删除但保留配置", parse: parseDpkgStatus, output: "deinstall ok config-files\t5.9-4", want: ""},
+		{name: "apt-cache policy", parse: func(pkg, output string) string { return fieldValue(output, "Candidate") }, output: "zsh:\n  Installed: (none)\n  Candidate: 5.9-4+b2\n  Version table:", want: "5.9-4+b2"},
+		{name: "apt-cache policy没有候选版本", parse: func(pkg, output string) string { return fieldValue(output, "Candidate") }, output: "zsh:\n  Installed: (none)\n  Candidate: (none)", want: ""},
+		{name: "rpm", parse: lastLine, output: "5.8-9.el9", want: "5.8-9.el9"},
+		{name: "yum list", parse: parseYumList, output: "Available Packages\nzsh.x86_64    5.8-9.el9    baseos", want: "5.8-9.el9"},
+		{name: "pacman -Q", parse: parseNameVersion, output: "zsh 5.9-5", want: "5.9-5"},
+		{name: "pacman -Si", parse: func(pkg, output string) string { return fieldValue(output, "Version") }, output: "Repository      : extra\nName            : zsh\nVersion         : 5.9-5\n", want: "5.9-5"},
+		{name: "apk list", parse: parseApkList, output: "zsh-vcs-5.9-r4 x86_64 {zsh} (MIT)\nzsh-5.9-r4 x86_64 {zsh} (MIT) [installed]", want: "5.9-r4"},
+		{name: "brew list", parse: parseNameVersion, output: "fd 10.1.0 10.2.0", want: "10.2.0"},
+		{name: "brew info", parse: parseBrewInfo, output: `{"formulae":[{"name":"fd","versions":{"stable":"10.2.0"}}],"casks":[]}`, want: "10.2.0"},
+		{name: "port installed", parse: parsePortInstalled, output: "The following ports are currently installed:\n  zsh @5.9_0\n  zsh @5.9_1 (active)", want: "5.9_1"},
+		{name: "nix-env", parse: parseNixName, output: "zsh-5.9", want: "5.9"},
+		{name: "nix-env属性路径", parse: parseNixVersion, output: "python3.11-pip-23.2.1", want: "23.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.parse("zsh", tt.output); got != tt.want {
+				t.Errorf("parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstallerQuery(t *testing.T) {
+	// 模拟的pacman: 只安装了zsh
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1 $2" in
+"-Q zsh") echo "zsh 5.9-4" ;;
+"-Q "*) echo "error: package '$2' was not found" >&2; exit 1 ;;
+"-Si zsh") printf 'Name            : zsh\nVersion         : 5.9-5\n' ;;
+*) exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "pacman"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	installer := newInstaller("pacman", map[string]string{"ID": "arch"}, true, logger)
+
+	if !installer.IsPackageInstalled("zsh") {
+		t.Error("IsPackageInstalled(zsh) = false")
+	}
+	if installer.IsPackageInstalled("fd") {
+		t.Error("IsPackageInstalled(fd) = true")
+	}
+	if version, err := installer.InstalledVersion("zsh"); err != nil || version != "5.9-4" {
+		t.Errorf("InstalledVersion(zsh) = %q, %v", version, err)
+	}
+	if version, err := installer.CandidateVersion("zsh"); err != nil || version != "5.9-5" {
+		t.Errorf("CandidateVersion(zsh) = %q, %v", version, err)
+	}
+	if _, err := installer.CandidateVersion("fd"); err == nil {
+		t.Error("CandidateVersion(fd) error = nil")
+	}
+}
+
+func TestNixInstallerQuery(t *testing.T) {
+	// 模拟的nix-env: 按derivation名称查询已安装的软件包, 按属性路径查询软件源
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1 $2" in
+"-qaA nixpkgs.python3Packages.pip") echo "python3.11-pip-23.2.1" ;;
+"-qaA nixpkgs.zsh") echo "zsh-5.9" ;;
+"-q python3.11-pip") echo "python3.11-pip-23.1" ;;
+"-q "*) echo "error: selector '$2' matches no derivations" >&2; exit 1 ;;
+*) echo "error: attribute '$2' in selection path '$2' not found" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "nix-env"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	installer := newInstaller("nix-env", nil, false, logger)
+
+	// pip 在nix-env中是属性路径 python3Packages.pip
+	if version, err := installer.InstalledVersion("pip"); err != nil || version != "23.1" {
+		t.Errorf("InstalledVersion(pip) = %q, %v", version, err)
+	}
+	if version, err := installer.CandidateVersion("pip"); err != nil || version != "23.2.1" {
+		t.Errorf("CandidateVersion(pip) = %q, %v", version, err)
+	}
+	if installer.IsPackageInstalled("zsh") {
+		t.Error("IsPackageInstalled(zsh) = true")
+	}
+	if version, err := installer.CandidateVersion("zsh"); err != nil || version != "5.9" {
+		t.Errorf("CandidateVersion(zsh) = %q, %v", version, err)
+	}
+	if _, err := installer.CandidateVersion("fd"); err == nil {
+		t.Error("CandidateVersion(fd) error = nil")
+	}
+}
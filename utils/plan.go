@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/sirupsen/logrus"
+)
+
+// PlanStep 记录 dry-run 模式下本应执行的一个操作
+type PlanStep struct {
+	Action string
+	Target string
+	Detail string
+}
+
+var (
+	dryRun bool
+	plan   []PlanStep
+	planMu sync.Mutex
+)
+
+// SetDryRun 开启或关闭 dry-run 模式。开启后所有会修改系统的操作只记录不执行
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// IsDryRun 返回当前是否处于 dry-run 模式
+func IsDryRun() bool {
+	return dryRun
+}
+
+// recordStep 在 dry-run 模式下记录一个操作
+func recordStep(logger *logrus.Logger, action, target, detail string) {
+	planMu.Lock()
+	plan = append(plan, PlanStep{Action: action, Target: target, Detail: detail})
+	planMu.Unlock()
+	logger.Infof("[dry-run] %s %s", action, target)
+}
+
+// GetPlan 返回 dry-run 模式下记录的所有操作
+func GetPlan() []PlanStep {
+	planMu.Lock()
+	defer planMu.Unlock()
+	steps := make([]PlanStep, len(plan))
+	copy(steps, plan)
+	return steps
+}
+
+// RenderPlan 以表格形式输出 dry-run 模式下记录的所有操作
+func RenderPlan(mirror io.Writer) error {
+	steps := GetPlan()
+	if len(steps) == 0 {
+		_, err := fmt.Fprintln(mirror, "没有需要执行的操作")
+		return err
+	}
+	cfg := TableConfig{
+		Header: table.Row{"序号", "操作", "目标", "详情"},
+	}
+	for i, step := range steps {
+		cfg.Data = append(cfg.Data, table.Row{i + 1, step.Action, step.Target, strings.TrimSpace(step.Detail)})
+	}
+	return RenderTable(&cfg, mirror)
+}
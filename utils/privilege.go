@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNoPrivilege 表示需要root权限, 但当前用户不是root且没有可用的sudo或doas
+var ErrNoPrivilege = errors.New("需要root权限, 但未找到sudo或doas")
+
+// ErrPasswordRequired 表示非交互模式下提权需要输入密码
+var ErrPasswordRequired = errors.New("非交互模式下无法输入密码, 请配置免密sudo/doas或以root用户运行")
+
+// 支持的提权工具, 按优先级排列
+var privilegeTools = []string{"sudo", "doas"}
+
+var (
+	nonInteractive bool
+
+	privilegeOnce sync.Once
+	privilegeTool string
+
+	privilegeMu sync.Mutex
+	// 已经成功验证过提权, 之后不再询问密码
+	privilegeValidated bool
+)
+
+// SetNonInteractive 开启或关闭非交互模式。开启后提权不会询问密码, 需要密码时直接失败
+func SetNonInteractive(enabled bool) {
+	nonInteractive = enabled
+}
+
+// IsNonInteractive 返回当前是否处于非交互模式
+func IsNonInteractive() bool {
+	return nonInteractive
+}
+
+// PrivilegeTool 返回可用的提权工具(sudo 或 doas), 都没有时返回空
+func PrivilegeTool() string {
+	privilegeOnce.Do(func() {
+		for _, tool := range privilegeTools {
+			if IsCommandAvailable(tool) {
+				privilegeTool = tool
+				return
+			}
+		}
+	})
+	return privilegeTool
+}
+
+// privilegeCommand 返回提权执行命令的前缀, 非交互模式下不询问密码, 设置了 SUDO_ASKPASS 时通过它获取密码
+func privilegeCommand(tool string) []string {
+	switch {
+	case tool == "":
+		return nil
+	case nonInteractive:
+		return []string{tool, "-n"}
+	case tool == "sudo" && os.Getenv("SUDO_ASKPASS") != "":
+		return []string{"sudo", "-A"}
+	}
+	return []string{tool}
+}
+
+// ValidatePrivilege 验证当前用户可以提权, 需要时在终端询问密码。
+// 非交互模式下需要密码时返回 ErrPasswordRequired, 没有提权工具时返回 ErrNoPrivilege
+func ValidatePrivilege(isRoot bool, logger *logrus.Logger) error {
+	if isRoot || IsDryRun() {
+		return nil
+	}
+	privilegeMu.Lock()
+	defer privilegeMu.Unlock()
+	if privilegeValidated {
+		return nil
+	}
+
+	tool := PrivilegeTool()
+	if tool == "" {
+		return ErrNoPrivilege
+	}
+	// doas 没有类似 sudo -v 的命令, 执行一个空命令来验证
+	args := append(privilegeCommand(tool), "true")
+	if tool == "sudo" {
+		args = append(privilegeCommand(tool), "-v")
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	if nonInteractive {
+		if err := cmd.Run(); err != nil {
+			return ErrPasswordRequired
+		}
+	} else {
+		logger.Infof("当前用户不是root用户,需要%s权限,请输入密码", tool)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s验证失败: %w", tool, err)
+		}
+	}
+	privilegeValidated = true
+	return nil
+}
+
+// HasPrivilege 返回当前用户是否可以提权: root用户, 或有可用的提权工具且在非交互模式下无需密码
+func HasPrivilege(isRoot bool) bool {
+	if isRoot {
+		return true
+	}
+	tool := PrivilegeTool()
+	if tool == "" {
+		return false
+	}
+	if !nonInteractive {
+		return true
+	}
+	return exec.Command(tool, "-n", "true").Run() == nil
+}
+
+// KeepSudoAlive 每隔interval在后台刷新一次sudo凭据, 避免长时间运行时凭据过期而再次询问密码。
+// 调用前需要已经通过 ValidatePrivilege 获取凭据, 返回的函数用于停止刷新。doas 不需要刷新
+func KeepSudoAlive(interval time.Duration, logger *logrus.Logger) func() {
+	if PrivilegeTool() != "sudo" {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// -n 不会询问密码, 凭据已经过期时直接失败
+				if err := exec.Command("sudo", "-n", "-v").Run(); err != nil {
+					logger.Debugf("刷新sudo凭据失败: %s", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
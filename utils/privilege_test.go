@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// usePrivilegeTool 在测试中使用指定的提权工具, 为空表示没有提权工具
+func usePrivilegeTool(t *testing.T, tool string) {
+	t.Helper()
+	PrivilegeTool()
+	saved := privilegeTool
+	privilegeTool = tool
+	t.Cleanup(func() { privilegeTool = saved })
+}
+
+func TestGenerateCmd(t *testing.T) {
+	tests := []struct {
+		name           string
+		tool           string
+		isSudo         bool
+		isRoot         bool
+		nonInteractive bool
+		askpass        string
+		want           string
+	}{
+		{name: "不需要提权", tool: "sudo", want: "ls"},
+		{name: "root用户", tool: "sudo", isSudo: true, isRoot: true, want: "ls"},
+		{name: "sudo", tool: "sudo", isSudo: true, want: "sudo ls"},
+		{name: "doas", tool: "doas", isSudo: true, want: "doas ls"},
+		{name: "非交互模式", tool: "sudo", isSudo: true, nonInteractive: true, want: "sudo -n ls"},
+		{name: "doas非交互模式", tool: "doas", isSudo: true, nonInteractive: true, want: "doas -n ls"},
+		{name: "SUDO_ASKPASS", tool: "sudo", isSudo: true, askpass: "/usr/bin/ssh-askpass", want: "sudo -A ls"},
+		{name: "非交互模式优先于SUDO_ASKPASS", tool: "sudo", isSudo: true, nonInteractive: true, askpass: "/usr/bin/ssh-askpass", want: "sudo -n ls"},
+		{name: "没有提权工具", isSudo: true, want: "ls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePrivilegeTool(t, tt.tool)
+			t.Setenv("SUDO_ASKPASS", tt.askpass)
+			SetNonInteractive(tt.nonInteractive)
+			defer SetNonInteractive(false)
+			if got := GenerateCmd("ls", tt.isSudo, tt.isRoot); got != tt.want {
+				t.Errorf("GenerateCmd() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePrivilege(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	// 模拟的sudo: 免密时退出码为0, 需要密码时为1
+	dir := t.TempDir()
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	writeSudo := func(code string) {
+		script := "#!/bin/sh\nexit " + code + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		exitCode string
+		isRoot   bool
+		want     error
+		wantHas  bool
+	}{
+		{name: "root用户", isRoot: true, wantHas: true},
+		{name: "没有提权工具", want: ErrNoPrivilege},
+		{name: "需要密码", tool: "sudo", exitCode: "1", want: ErrPasswordRequired},
+		{name: "免密sudo", tool: "sudo", exitCode: "0", wantHas: true},
+	}
+	SetNonInteractive(true)
+	defer SetNonInteractive(false)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePrivilegeTool(t, tt.tool)
+			writeSudo(tt.exitCode)
+			privilegeValidated = false
+			defer func() { privilegeValidated = false }()
+
+			if err := ValidatePrivilege(tt.isRoot, logger); !errors.Is(err, tt.want) {
+				t.Errorf("ValidatePrivilege() error = %v, want %v", err, tt.want)
+			}
+			if got := HasPrivilege(tt.isRoot); got != tt.wantHas {
+				t.Errorf("HasPrivilege() = %v, want %v", got, tt.wantHas)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 触发限流时, 重置时间在此范围内则等待后重试, 否则直接报错
+const maxRateLimitWait = time.Minute
+
+var githubToken string
+
+// SetGithubToken 设置访问GitHub API使用的令牌, 未设置时匿名访问(每小时60次)
+func SetGithubToken(token string) {
+	githubToken = token
+}
+
+// RateLimit 是GitHub API响应头 X-RateLimit-* 中的限额信息
+type RateLimit struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// RateLimitError 表示GitHub API请求被限流
+type RateLimitError struct {
+	Limit *RateLimit
+	// 次级限流时GitHub通过 Retry-After 指定的等待时间
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	var msg string
+	if e.RetryAfter > 0 {
+		msg = fmt.Sprintf("GitHub API请求过于频繁, 请%s后重试", e.RetryAfter)
+	} else {
+		msg = fmt.Sprintf("GitHub API请求次数已用完(上限%d次/小时), 将于%s重置",
+			e.Limit.Limit, e.Limit.Reset.Local().Format("15:04:05"))
+	}
+	if githubToken == "" {
+		msg += "。设置环境变量GITHUB_TOKEN或使用--github-token可以提高上限"
+	}
+	return msg
+}
+
+// parseRateLimit 解析响应头中的限额信息, 没有相关响应头时返回nil
+func parseRateLimit(header http.Header) *RateLimit {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return nil
+	}
+	remaining, _ := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	return &RateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+}
+
+// rateLimitError 判断403/429响应是否由限流导致
+func rateLimitError(resp *http.Response, limit *RateLimit) *RateLimitError {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return &RateLimitError{Limit: limit, RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	if limit != nil && limit.Remaining == 0 {
+		return &RateLimitError{Limit: limit}
+	}
+	return nil
+}
+
+func (e *RateLimitError) wait() time.Duration {
+	if e.RetryAfter > 0 {
+		return e.RetryAfter
+	}
+	// 多等一秒, 避免本机时间略快于GitHub
+	return time.Until(e.Limit.Reset) + time.Second
+}
+
+// apiGet 请求代码平台的API并将JSON响应解析到v
+func (g *GithubRepoInfo) apiGet(api string, v interface{}) error {
+	body, err := g.apiRequest(api)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// apiRequest 请求代码平台的API并返回响应内容, 被限流且很快就会重置时等待后重试一次
+func (g *GithubRepoInfo) apiRequest(api string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, api, nil)
+		if err != nil {
+			return nil, err
+		}
+		g.source.Authorize(req)
+		resp, err := g.httpClinet.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		limit := parseRateLimit(resp.Header)
+		if limit != nil && limit.Remaining > 0 && limit.Remaining <= 5 {
+			g.logger.Warnf("%s API剩余请求次数:%d, 将于%s重置", g.source.Name(), limit.Remaining, limit.Reset.Local().Format("15:04:05"))
+		}
+		if rlErr := rateLimitError(resp, limit); rlErr != nil {
+			if wait := rlErr.wait(); attempt == 0 && wait <= maxRateLimitWait {
+				g.logger.Warnf("%s, 等待%s后重试", rlErr, wait.Round(time.Second))
+				time.Sleep(wait)
+				continue
+			}
+			return nil, rlErr
+		}
+		if resp.StatusCode == http.StatusUnauthorized {
+			if isGithub(g.source) {
+				return nil, fmt.Errorf("GitHub令牌无效, 请检查GITHUB_TOKEN或--github-token")
+			}
+			return nil, fmt.Errorf("%s令牌无效, 请检查配置文件中的token", g.source.Name())
+		}
+		if resp.StatusCode != http.StatusOK {
+			var apiErr struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(body, &apiErr)
+			return nil, fmt.Errorf("响应状态码: %d %s", resp.StatusCode, apiErr.Message)
+		}
+		return body, nil
+	}
+}
+
+// GetRateLimit 查询GitHub API的剩余请求次数, 查询本身不消耗次数
+func GetRateLimit(httpsProxy string, logger *logrus.Logger) (*RateLimit, error) {
+	var result struct {
+		Rate struct {
+			Limit     int   `json:"limit"`
+			Remaining int   `json:"remaining"`
+			Reset     int64 `json:"reset"`
+		} `json:"rate"`
+	}
+	g := NewRepoInfo(GitHub, "", "", httpsProxy, "", logger)
+	g.httpClinet.Timeout = 10 * time.Second
+	if err := g.apiGet("https://api.github.com/rate_limit", &result); err != nil {
+		return nil, err
+	}
+	return &RateLimit{
+		Limit:     result.Rate.Limit,
+		Remaining: result.Rate.Remaining,
+		Reset:     time.Unix(result.Rate.Reset, 0),
+	}, nil
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReleaseAsset 是Release中的一个文件
+type ReleaseAsset struct {
+	Name               string `json:"name"`
+	Size               int64  `json:"size"`
+	ContentType        string `json:"content_type"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	// 文件的摘要, 格式为 "sha256:<哈希>", 较早发布的文件没有该字段
+	Digest string `json:"digest"`
+}
+
+// Release 是GitHub API返回的Release信息
+type Release struct {
+	TagName     string         `json:"tag_name"`
+	Name        string         `json:"name"`
+	Draft       bool           `json:"draft"`
+	Prerelease  bool           `json:"prerelease"`
+	PublishedAt time.Time      `json:"published_at"`
+	Assets      []ReleaseAsset `json:"assets"`
+}
+
+// AssetNames 返回Release中所有文件的名称
+func (r *Release) AssetNames() []string {
+	names := make([]string, 0, len(r.Assets))
+	for _, asset := range r.Assets {
+		names = append(names, asset.Name)
+	}
+	return names
+}
+
+// GetLatestRelease 获取最新的Release
+func (g *GithubRepoInfo) GetLatestRelease() (*Release, error) {
+	if activeBundle != nil {
+		return activeBundle.releaseInfo(g.ower, g.repo, "")
+	}
+	return g.getRelease(g.source.ReleaseAPI(g.ower, g.repo, ""))
+}
+
+// GetReleaseByTag 获取指定版本的Release
+func (g *GithubRepoInfo) GetReleaseByTag(tagName string) (*Release, error) {
+	if activeBundle != nil {
+		return activeBundle.releaseInfo(g.ower, g.repo, tagName)
+	}
+	return g.getRelease(g.source.ReleaseAPI(g.ower, g.repo, tagName))
+}
+
+func (g *GithubRepoInfo) getRelease(api string) (*Release, error) {
+	body, err := g.apiRequest(api)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s的Release信息失败: %w", g.repo, err)
+	}
+	release, err := g.source.DecodeRelease(body)
+	if err != nil {
+		return nil, fmt.Errorf("解析%s的Release信息失败: %w", g.repo, err)
+	}
+	if release.TagName == "" {
+		return nil, fmt.Errorf("获取%s的Release信息失败: 响应中没有tag_name", g.repo)
+	}
+	return release, nil
+}
+
+// 未在 AssetRule 中指定时, 各平台在文件名中常用的写法
+var (
+	defaultOSNames = map[string][]string{
+		"linux":  {"linux"},
+		"darwin": {"darwin", "macos", "apple"},
+	}
+	defaultArchNames = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x64"},
+		"arm64": {"arm64", "aarch64"},
+	}
+	// 校验和、签名等不是安装文件
+	ignoredAssetSuffixes = []string{".sha256", ".sha256sum", ".sha512", ".md5", ".asc", ".sig", ".pem", ".sbom", ".txt", ".json"}
+)
+
+// AssetRule 描述如何从Release的文件中选出适用于某个平台的文件。
+// 文件名(不区分大小写)需要同时包含操作系统和架构的任一写法, 并以 Suffixes 中的任一后缀结尾
+type AssetRule struct {
+	// 操作系统(GOOS)在文件名中的写法, 未指定的系统使用默认写法
+	OS map[string][]string
+	// 架构(GOARCH)在文件名中的写法, 未指定的架构使用默认写法
+	Arch map[string][]string
+	// 允许的文件后缀, 为空时不限制; 匹配到多个文件时优先选择靠前的后缀
+	Suffixes []string
+	// 文件名中包含这些内容时忽略
+	Exclude []string
+	// Fallback 在无法获取Release信息时推测指定版本的文件名, 为nil时直接报错
+	Fallback func(tagName, osType, arch string) string
+}
+
+// NoMatchingAssetError 表示Release中没有适用于当前平台的文件
+type NoMatchingAssetError struct {
+	Tag        string
+	OS         string
+	Arch       string
+	Candidates []string
+}
+
+func (e *NoMatchingAssetError) Error() string {
+	candidates := "无"
+	if len(e.Candidates) > 0 {
+		candidates = strings.Join(e.Candidates, ", ")
+	}
+	return fmt.Sprintf("Release %s中没有匹配%s/%s的文件, 候选: %s", e.Tag, e.OS, e.Arch, candidates)
+}
+
+func (r *AssetRule) names(custom map[string][]string, defaults map[string][]string, key string) []string {
+	if names, ok := custom[key]; ok {
+		return names
+	}
+	if names, ok := defaults[key]; ok {
+		return names
+	}
+	return []string{key}
+}
+
+// Match 从release中选出适用于osType/arch的文件
+func (r *AssetRule) Match(release *Release, osType, arch string) (*ReleaseAsset, error) {
+	osNames := r.names(r.OS, defaultOSNames, osType)
+	archNames := r.names(r.Arch, defaultArchNames, arch)
+
+	var matched []*ReleaseAsset
+	for i := range release.Assets {
+		asset := &release.Assets[i]
+		name := strings.ToLower(asset.Name)
+		if hasAnySuffix(name, ignoredAssetSuffixes) || containsAny(name, r.Exclude) {
+			continue
+		}
+		if len(r.Suffixes) > 0 && !hasAnySuffix(name, r.Suffixes) {
+			continue
+		}
+		if containsAny(name, osNames) && containsAny(name, archNames) {
+			matched = append(matched, asset)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, &NoMatchingAssetError{Tag: release.TagName, OS: osType, Arch: arch, Candidates: release.AssetNames()}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		pi, pj := r.suffixPriority(matched[i].Name), r.suffixPriority(matched[j].Name)
+		if pi != pj {
+			return pi < pj
+		}
+		return len(matched[i].Name) < len(matched[j].Name)
+	})
+	return matched[0], nil
+}
+
+func (r *AssetRule) suffixPriority(name string) int {
+	name = strings.ToLower(name)
+	for i, suffix := range r.Suffixes {
+		if strings.HasSuffix(name, strings.ToLower(suffix)) {
+			return i
+		}
+	}
+	return len(r.Suffixes)
+}
+
+func hasAnySuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(name string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(name, strings.ToLower(sub)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newTestRelease(names ...string) *Release {
+	release := &Release{TagName: "v1.0.0"}
+	for _, name := range names {
+		release.Assets = append(release.Assets, ReleaseAsset{Name: name})
+	}
+	return release
+}
+
+func TestAssetRuleMatch(t *testing.T) {
+	chsrcAssets := []string{
+		"chsrc-aarch64-linux", "chsrc-aarch64-macos", "chsrc-x64-linux",
+		"chsrc-x64-macos", "chsrc-x64-windows.exe", "chsrc-riscv64-linux",
+	}
+	chsrcRule := &AssetRule{
+		OS:      map[string][]string{"darwin": {"macos"}},
+		Arch:    map[string][]string{"amd64": {"x64"}, "arm64": {"aarch64"}},
+		Exclude: []string{".exe"},
+	}
+	tests := []struct {
+		name   string
+		rule   *AssetRule
+		assets []string
+		os     string
+		arch   string
+		want   string
+	}{
+		{
+			name:   "自定义的系统和架构写法",
+			rule:   chsrcRule,
+			assets: chsrcAssets,
+			os:     "darwin",
+			arch:   "arm64",
+			want:   "chsrc-aarch64-macos",
+		},
+		{
+			name:   "排除指定的文件",
+			rule:   chsrcRule,
+			assets: []string{"chsrc-x64-windows.exe", "chsrc-x64-linux"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "chsrc-x64-linux",
+		},
+		{
+			name:   "默认的系统和架构写法",
+			rule:   &AssetRule{},
+			assets: []string{"tool_Darwin_x86_64.tar.gz", "tool_Linux_x86_64.tar.gz", "tool_Linux_arm64.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "tool_Linux_x86_64.tar.gz",
+		},
+		{
+			name:   "忽略校验和与签名文件",
+			rule:   &AssetRule{},
+			assets: []string{"tool-linux-amd64.tar.gz.sha256", "tool-linux-amd64.tar.gz.sig", "tool-linux-amd64.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "tool-linux-amd64.tar.gz",
+		},
+		{
+			name:   "按后缀的先后顺序选择",
+			rule:   &AssetRule{Suffixes: []string{".tar.gz", ".zip"}},
+			assets: []string{"tool-linux-amd64.zip", "tool-linux-amd64.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "tool-linux-amd64.tar.gz",
+		},
+		{
+			name:   "后缀优先于文件名长度",
+			rule:   &AssetRule{Suffixes: []string{".tar.gz", ".zip"}},
+			assets: []string{"t-linux-amd64.zip", "tool-linux-amd64-full.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "tool-linux-amd64-full.tar.gz",
+		},
+		{
+			name:   "不在后缀列表中的文件被忽略",
+			rule:   &AssetRule{Suffixes: []string{".tar.gz"}},
+			assets: []string{"tool-linux-amd64.deb", "tool-linux-amd64.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "tool-linux-amd64.tar.gz",
+		},
+		{
+			name:   "后缀相同时选择最短的文件名",
+			rule:   &AssetRule{Suffixes: []string{".tar.gz"}},
+			assets: []string{"tool-linux-amd64-musl.tar.gz", "tool-linux-amd64.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "tool-linux-amd64.tar.gz",
+		},
+		{
+			name:   "后缀不区分大小写",
+			rule:   &AssetRule{Suffixes: []string{".TAR.GZ"}},
+			assets: []string{"Tool-Linux-AMD64.tar.gz"},
+			os:     "linux",
+			arch:   "amd64",
+			want:   "Tool-Linux-AMD64.tar.gz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asset, err := tt.rule.Match(newTestRelease(tt.assets...), tt.os, tt.arch)
+			if err != nil {
+				t.Fatalf("Match() error = %v", err)
+			}
+			if asset.Name != tt.want {
+				t.Errorf("Match() = %s, want %s", asset.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssetRuleMatchNoMatch(t *testing.T) {
+	assets := []string{"tool-linux-amd64.tar.gz", "tool-darwin-amd64.tar.gz"}
+	_, err := (&AssetRule{}).Match(newTestRelease(assets...), "linux", "arm64")
+	var noMatch *NoMatchingAssetError
+	if !errors.As(err, &noMatch) {
+		t.Fatalf("Match() error = %v, want NoMatchingAssetError", err)
+	}
+	if noMatch.Tag != "v1.0.0" || noMatch.OS != "linux" || noMatch.Arch != "arm64" {
+		t.Errorf("NoMatchingAssetError = %+v", noMatch)
+	}
+	if !reflect.DeepEqual(noMatch.Candidates, assets) {
+		t.Errorf("Candidates = %v, want %v", noMatch.Candidates, assets)
+	}
+}
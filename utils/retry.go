@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryPolicy 控制网络操作失败后的重试
+type RetryPolicy struct {
+	// 失败后的重试次数
+	Retries int
+	// 第一次重试前的等待时间, 之后每次翻倍
+	Delay time.Duration
+}
+
+// 重试等待时间的上限
+const maxRetryDelay = 30 * time.Second
+
+var (
+	retryPolicy   = RetryPolicy{Retries: 2, Delay: time.Second}
+	githubMirrors []string
+)
+
+// SetRetryPolicy 设置网络操作的重试策略
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy = policy
+}
+
+// SetGithubMirrors 设置--github-proxy 和直连都失败后依次尝试的GitHub镜像
+func SetGithubMirrors(mirrors []string) {
+	githubMirrors = mirrors
+}
+
+// HTTPStatusError 表示HTTP请求返回了非预期的状态码
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("请求%s失败, 响应状态码: %d", e.URL, e.StatusCode)
+}
+
+// retryable 判断错误是否值得在同一个下载源上重试, 404等客户端错误重试也不会成功
+func retryable(err error) bool {
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// retry 执行fn, 失败后按指数退避重试
+func retry(logger *logrus.Logger, desc string, fn func() error) error {
+	delay := retryPolicy.Delay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retryPolicy.Retries || !retryable(err) {
+			return err
+		}
+		logger.Warnf("%s失败:%s, %s后第%d次重试", desc, err, delay, attempt+1)
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// sources 返回依次尝试的GitHub下载源: --github-proxy、直连、配置文件中的镜像。空字符串表示直连。
+// 明确指定了代理说明直连不可用或很慢, 因此优先使用代理
+func (g *GithubRepoInfo) sources() []string {
+	if !isPublicGithub(g.source) {
+		// 代理和镜像只能加速公共的GitHub, 其他平台和GitHub Enterprise Server只能直连
+		return []string{""}
+	}
+	var sources []string
+	seen := make(map[string]bool)
+	for _, src := range append([]string{g.githubProxy, ""}, githubMirrors...) {
+		if !seen[src] {
+			seen[src] = true
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+func sourceName(src string) string {
+	if src == "" {
+		return "直连"
+	}
+	return src
+}
+
+// sourceUrl 返回通过下载源访问rawUrl的地址
+func sourceUrl(src, rawUrl string) string {
+	if src == "" {
+		return rawUrl
+	}
+	return JoinURL(src, rawUrl)
+}
+
+// withSources 依次使用各个下载源执行fn, 每个下载源失败后先重试, 仍失败再换下一个
+func (g *GithubRepoInfo) withSources(desc string, fn func(src string) error) error {
+	var errs []string
+	for _, src := range g.sources() {
+		err := retry(g.logger, fmt.Sprintf("%s(%s)", desc, sourceName(src)), func() error { return fn(src) })
+		if err == nil {
+			g.logger.Infof("%s成功, 使用的下载源: %s", desc, sourceName(src))
+			return nil
+		}
+		g.logger.Warnf("%s(%s)失败:%s", desc, sourceName(src), err)
+		errs = append(errs, fmt.Sprintf("%s: %s", sourceName(src), err))
+	}
+	return fmt.Errorf("%s失败, 所有下载源均不可用: %s", desc, strings.Join(errs, "; "))
+}
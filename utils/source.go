@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Source 是托管Release和Git仓库的代码平台。
+// GithubRepoInfo 通过 Source 获取各平台的地址和Release信息, 下载、缓存、重试和克隆的逻辑对所有平台通用
+type Source interface {
+	// Name 返回平台名称, 用于日志
+	Name() string
+	// ReleaseAPI 返回查询Release的API地址, tag为空时查询最新的Release
+	ReleaseAPI(owner, repo, tag string) string
+	// DecodeRelease 将API响应转换为 Release
+	DecodeRelease(body []byte) (*Release, error)
+	// ReleaseFileUrl 返回Release文件的下载地址
+	ReleaseFileUrl(owner, repo, tag, asset string) string
+	// RepoUrl 返回仓库的克隆地址
+	RepoUrl(owner, repo string) string
+	// Authorize 为API请求设置认证信息, 没有令牌时不做处理
+	Authorize(req *http.Request)
+	// GitAuth 返回克隆和拉取仓库使用的认证信息, 没有令牌时返回nil
+	GitAuth() transport.AuthMethod
+}
+
+// 支持的平台类型
+const (
+	SourceGithub = "github"
+	SourceGitee  = "gitee"
+	SourceGitlab = "gitlab"
+	SourceGitea  = "gitea"
+)
+
+// GitHub 是默认的代码平台
+var GitHub Source = &GithubSource{API: "https://api.github.com", Web: "https://github.com"}
+
+// NewSource 根据平台类型创建 Source, baseUrl 为空时使用各平台的公共服务地址
+func NewSource(sourceType, baseUrl, token string) (Source, error) {
+	baseUrl = strings.TrimSuffix(baseUrl, "/")
+	switch sourceType {
+	case SourceGithub:
+		if baseUrl == "" || baseUrl == "https://github.com" {
+			return GitHub, nil
+		}
+		// GitHub Enterprise Server 的API位于 /api/v3
+		return &GithubSource{API: baseUrl + "/api/v3", Web: baseUrl, Token: token}, nil
+	case SourceGitee:
+		if baseUrl == "" {
+			baseUrl = "https://gitee.com"
+		}
+		return &GiteeSource{URL: baseUrl, Token: token}, nil
+	case SourceGitlab:
+		if baseUrl == "" {
+			baseUrl = "https://gitlab.com"
+		}
+		return &GitlabSource{URL: baseUrl, Token: token}, nil
+	case SourceGitea:
+		if baseUrl == "" {
+			baseUrl = "https://gitea.com"
+		}
+		return &GiteaSource{URL: baseUrl, Token: token}, nil
+	default:
+		return nil, fmt.Errorf("不支持的代码平台类型: %s (可选值: github, gitee, gitlab, gitea)", sourceType)
+	}
+}
+
+// RepoSource 指定从哪个平台的哪个仓库获取一个GitHub仓库的内容
+type RepoSource struct {
+	Source Source
+	Owner  string
+	Repo   string
+}
+
+var repoSources map[string]RepoSource
+
+// SetRepoSources 设置改为从其他平台获取的仓库, 键为GitHub上的 owner/repo
+func SetRepoSources(sources map[string]RepoSource) {
+	repoSources = sources
+}
+
+// GithubSource 是GitHub或GitHub Enterprise Server。Token 为空时使用 --github-token 设置的令牌
+type GithubSource struct {
+	API   string
+	Web   string
+	Token string
+}
+
+func (s *GithubSource) Name() string {
+	return "GitHub"
+}
+
+func (s *GithubSource) ReleaseAPI(owner, repo, tag string) string {
+	if tag == "" {
+		return fmt.Sprintf("%s/repos/%s/%s/releases/latest", s.API, owner, repo)
+	}
+	return fmt.Sprintf("%s/repos/%s/%s/releases/tags/%s", s.API, owner, repo, tag)
+}
+
+func (s *GithubSource) DecodeRelease(body []byte) (*Release, error) {
+	release := &Release{}
+	if err := json.Unmarshal(body, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+func (s *GithubSource) ReleaseFileUrl(owner, repo, tag, asset string) string {
+	return fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", s.Web, owner, repo, tag, asset)
+}
+
+func (s *GithubSource) RepoUrl(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", s.Web, owner, repo)
+}
+
+func (s *GithubSource) token() string {
+	if s.Token != "" {
+		return s.Token
+	}
+	return githubToken
+}
+
+func (s *GithubSource) Authorize(req *http.Request) {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := s.token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// GitAuth 克隆公共GitHub上的仓库不需要令牌, 也避免把令牌发送给 --github-proxy 和镜像;
+// GitHub Enterprise Server 只能直连, 使用令牌才能克隆私有仓库
+func (s *GithubSource) GitAuth() transport.AuthMethod {
+	if isPublicGithub(s) {
+		return nil
+	}
+	return tokenAuth(s.token())
+}
+
+// GiteaSource 是Gitea或Forgejo, API与GitHub基本一致
+type GiteaSource struct {
+	URL   string
+	Token string
+}
+
+func (s *GiteaSource) Name() string {
+	return "Gitea"
+}
+
+func (s *GiteaSource) ReleaseAPI(owner, repo, tag string) string {
+	if tag == "" {
+		return fmt.Sprintf("%s/api/v1/repos/%s/%s/releases/latest", s.URL, owner, repo)
+	}
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s/releases/tags/%s", s.URL, owner, repo, tag)
+}
+
+func (s *GiteaSource) DecodeRelease(body []byte) (*Release, error) {
+	release := &Release{}
+	if err := json.Unmarshal(body, release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+func (s *GiteaSource) ReleaseFileUrl(owner, repo, tag, asset string) string {
+	return fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", s.URL, owner, repo, tag, asset)
+}
+
+func (s *GiteaSource) RepoUrl(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", s.URL, owner, repo)
+}
+
+func (s *GiteaSource) Authorize(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "token "+s.Token)
+	}
+}
+
+func (s *GiteaSource) GitAuth() transport.AuthMethod {
+	return tokenAuth(s.Token)
+}
+
+// GiteeSource 是Gitee, API与GitHub类似, 令牌通过 access_token 参数传递
+type GiteeSource struct {
+	URL   string
+	Token string
+}
+
+func (s *GiteeSource) Name() string {
+	return "Gitee"
+}
+
+func (s *GiteeSource) ReleaseAPI(owner, repo, tag string) string {
+	if tag == "" {
+		return fmt.Sprintf("%s/api/v5/repos/%s/%s/releases/latest", s.URL, owner, repo)
+	}
+	return fmt.Sprintf("%s/api/v5/repos/%s/%s/releases/tags/%s", s.URL, owner, repo, tag)
+}
+
+func (s *GiteeSource) DecodeRelease(body []byte) (*Release, error) {
+	var raw struct {
+		TagName    string    `json:"tag_name"`
+		Name       string    `json:"name"`
+		Prerelease bool      `json:"prerelease"`
+		CreatedAt  time.Time `json:"created_at"`
+		Assets     []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	release := &Release{TagName: raw.TagName, Name: raw.Name, Prerelease: raw.Prerelease, PublishedAt: raw.CreatedAt}
+	for _, asset := range raw.Assets {
+		// Gitee会把源码压缩包也列为文件, 但没有文件名
+		if asset.Name == "" {
+			continue
+		}
+		release.Assets = append(release.Assets, ReleaseAsset{Name: asset.Name, BrowserDownloadURL: asset.BrowserDownloadURL})
+	}
+	return release, nil
+}
+
+func (s *GiteeSource) ReleaseFileUrl(owner, repo, tag, asset string) string {
+	return fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", s.URL, owner, repo, tag, asset)
+}
+
+func (s *GiteeSource) RepoUrl(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", s.URL, owner, repo)
+}
+
+func (s *GiteeSource) Authorize(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	if s.Token != "" {
+		query := req.URL.Query()
+		query.Set("access_token", s.Token)
+		req.URL.RawQuery = query.Encode()
+	}
+}
+
+func (s *GiteeSource) GitAuth() transport.AuthMethod {
+	return tokenAuth(s.Token)
+}
+
+// GitlabSource 是GitLab。Release文件需要以 /<文件名> 作为链接的 filepath 发布, 才能通过文件名下载
+type GitlabSource struct {
+	URL   string
+	Token string
+}
+
+func (s *GitlabSource) Name() string {
+	return "GitLab"
+}
+
+func (s *GitlabSource) ReleaseAPI(owner, repo, tag string) string {
+	project := url.PathEscape(owner + "/" + repo)
+	if tag == "" {
+		return fmt.Sprintf("%s/api/v4/projects/%s/releases/permalink/latest", s.URL, project)
+	}
+	return fmt.Sprintf("%s/api/v4/projects/%s/releases/%s", s.URL, project, url.PathEscape(tag))
+}
+
+func (s *GitlabSource) DecodeRelease(body []byte) (*Release, error) {
+	var raw struct {
+		TagName         string    `json:"tag_name"`
+		Name            string    `json:"name"`
+		UpcomingRelease bool      `json:"upcoming_release"`
+		ReleasedAt      time.Time `json:"released_at"`
+		Assets          struct {
+			Links []struct {
+				Name           string `json:"name"`
+				URL            string `json:"url"`
+				DirectAssetURL string `json:"direct_asset_url"`
+			} `json:"links"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	release := &Release{TagName: raw.TagName, Name: raw.Name, Prerelease: raw.UpcomingRelease, PublishedAt: raw.ReleasedAt}
+	for _, link := range raw.Assets.Links {
+		downloadUrl := link.DirectAssetURL
+		if downloadUrl == "" {
+			downloadUrl = link.URL
+		}
+		release.Assets = append(release.Assets, ReleaseAsset{Name: link.Name, BrowserDownloadURL: downloadUrl})
+	}
+	return release, nil
+}
+
+func (s *GitlabSource) ReleaseFileUrl(owner, repo, tag, asset string) string {
+	return fmt.Sprintf("%s/%s/%s/-/releases/%s/downloads/%s", s.URL, owner, repo, tag, asset)
+}
+
+func (s *GitlabSource) RepoUrl(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s.git", s.URL, owner, repo)
+}
+
+func (s *GitlabSource) Authorize(req *http.Request) {
+	req.Header.Set("Accept", "application/json")
+	if s.Token != "" {
+		req.Header.Set("PRIVATE-TOKEN", s.Token)
+	}
+}
+
+func (s *GitlabSource) GitAuth() transport.AuthMethod {
+	return tokenAuth(s.Token)
+}
+
+// tokenAuth 使用令牌作为密码进行HTTP认证, GitHub Enterprise Server、GitLab、Gitea和Gitee都支持这种方式
+func tokenAuth(token string) transport.AuthMethod {
+	if token == "" {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: "oauth2", Password: token}
+}
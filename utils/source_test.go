@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// fakeForge 模拟代码平台的Release API和文件下载
+type fakeForge struct {
+	// API路径到响应内容的映射
+	api map[string]string
+	// 下载路径到文件内容的映射
+	files map[string]string
+	// 收到的认证信息
+	auth []string
+}
+
+func (f *fakeForge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.auth = append(f.auth, r.Header.Get("Authorization")+r.Header.Get("PRIVATE-TOKEN")+r.URL.Query().Get("access_token"))
+	if body, ok := f.api[r.URL.EscapedPath()]; ok {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+		return
+	}
+	if body, ok := f.files[r.URL.EscapedPath()]; ok {
+		fmt.Fprint(w, body)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func TestSources(t *testing.T) {
+	tests := []struct {
+		name      string
+		newSource func(url string) Source
+		owner     string
+		repo      string
+		api       map[string]string
+		files     map[string]string
+		wantAuth  string
+		wantAsset string
+		wantRepo  string
+	}{
+		{
+			name:      "GitHub",
+			newSource: func(url string) Source { return &GithubSource{API: url + "/api/v3", Web: url, Token: "gh"} },
+			owner:     "o",
+			repo:      "r",
+			api: map[string]string{
+				"/api/v3/repos/o/r/releases/latest":  `{"tag_name":"v2","assets":[{"name":"r-linux-amd64.tar.gz","digest":"sha256:abc"}]}`,
+				"/api/v3/repos/o/r/releases/tags/v1": `{"tag_name":"v1","assets":[{"name":"r-linux-amd64.tar.gz"}]}`,
+			},
+			files:     map[string]string{"/o/r/releases/download/v1/r-linux-amd64.tar.gz": "github"},
+			wantAuth:  "Bearer gh",
+			wantAsset: "r-linux-amd64.tar.gz",
+			wantRepo:  "/o/r.git",
+		},
+		{
+			name:      "Gitea",
+			newSource: func(url string) Source { return &GiteaSource{URL: url, Token: "tea"} },
+			owner:     "o",
+			repo:      "r",
+			api: map[string]string{
+				"/api/v1/repos/o/r/releases/latest":  `{"tag_name":"v2","assets":[{"name":"r-linux-amd64.tar.gz","size":6}]}`,
+				"/api/v1/repos/o/r/releases/tags/v1": `{"tag_name":"v1","assets":[{"name":"r-linux-amd64.tar.gz","size":5}]}`,
+			},
+			files:     map[string]string{"/o/r/releases/download/v1/r-linux-amd64.tar.gz": "gitea"},
+			wantAuth:  "token tea",
+			wantAsset: "r-linux-amd64.tar.gz",
+			wantRepo:  "/o/r.git",
+		},
+		{
+			name:      "Gitee",
+			newSource: func(url string) Source { return &GiteeSource{URL: url, Token: "ee"} },
+			owner:     "o",
+			repo:      "r",
+			api: map[string]string{
+				"/api/v5/repos/o/r/releases/latest":  `{"tag_name":"v2","assets":[{"name":"r-linux-amd64.tar.gz"},{"browser_download_url":"https://gitee.com/o/r/archive/refs/tags/v2.zip"}]}`,
+				"/api/v5/repos/o/r/releases/tags/v1": `{"tag_name":"v1","assets":[{"name":"r-linux-amd64.tar.gz"}]}`,
+			},
+			files:     map[string]string{"/o/r/releases/download/v1/r-linux-amd64.tar.gz": "gitee"},
+			wantAuth:  "ee",
+			wantAsset: "r-linux-amd64.tar.gz",
+			wantRepo:  "/o/r.git",
+		},
+		{
+			name:      "GitLab子群组",
+			newSource: func(url string) Source { return &GitlabSource{URL: url, Token: "lab"} },
+			owner:     "group/sub",
+			repo:      "r",
+			api: map[string]string{
+				"/api/v4/projects/group%2Fsub%2Fr/releases/permalink/latest": `{"tag_name":"v2","assets":{"links":[{"name":"r-linux-amd64.tar.gz","direct_asset_url":"x"}]}}`,
+				"/api/v4/projects/group%2Fsub%2Fr/releases/v1":               `{"tag_name":"v1","assets":{"links":[{"name":"r-linux-amd64.tar.gz","url":"x"}]}}`,
+			},
+			files:     map[string]string{"/group/sub/r/-/releases/v1/downloads/r-linux-amd64.tar.gz": "gitlab"},
+			wantAuth:  "lab",
+			wantAsset: "r-linux-amd64.tar.gz",
+			wantRepo:  "/group/sub/r.git",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forge := &fakeForge{api: tt.api, files: tt.files}
+			server := httptest.NewServer(forge)
+			defer server.Close()
+
+			g := NewRepoInfo(tt.newSource(server.URL), tt.owner, tt.repo, "", "https://ghproxy.example/", newTestLogger())
+			if g.GithubProxy() != "" && !isGithub(g.Source()) {
+				t.Errorf("非GitHub平台不应使用 --github-proxy")
+			}
+
+			latest, err := g.GetLatestRelease()
+			if err != nil {
+				t.Fatalf("GetLatestRelease() error = %v", err)
+			}
+			if latest.TagName != "v2" || len(latest.Assets) != 1 || latest.Assets[0].Name != tt.wantAsset {
+				t.Errorf("GetLatestRelease() = %+v", latest)
+			}
+			release, err := g.GetReleaseByTag("v1")
+			if err != nil {
+				t.Fatalf("GetReleaseByTag() error = %v", err)
+			}
+			if release.TagName != "v1" {
+				t.Errorf("GetReleaseByTag() = %+v", release)
+			}
+			if forge.auth[0] != tt.wantAuth {
+				t.Errorf("认证信息 = %q, want %q", forge.auth[0], tt.wantAuth)
+			}
+
+			if !isGithub(g.Source()) {
+				// 不经过代理直接下载, 避免测试访问外网
+				dst := filepath.Join(t.TempDir(), tt.wantAsset)
+				if err := g.DownloadReleaseLatestFile(dst, tt.wantAsset, "v1"); err != nil {
+					t.Fatalf("DownloadReleaseLatestFile() error = %v", err)
+				}
+				content, _ := os.ReadFile(dst)
+				for _, want := range tt.files {
+					if string(content) != want {
+						t.Errorf("下载的文件内容 = %q, want %q", content, want)
+					}
+				}
+			}
+			if got, want := g.GetOriginRepoUrl(), server.URL+tt.wantRepo; got != want {
+				t.Errorf("GetOriginRepoUrl() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGithubSourceDownload(t *testing.T) {
+	forge := &fakeForge{files: map[string]string{"/o/r/releases/download/v1/r.tar.gz": "github"}}
+	server := httptest.NewServer(forge)
+	defer server.Close()
+
+	g := NewRepoInfo(&GithubSource{API: server.URL, Web: server.URL}, "o", "r", "", "", newTestLogger())
+	dst := filepath.Join(t.TempDir(), "r.tar.gz")
+	if err := g.DownloadReleaseLatestFile(dst, "r.tar.gz", "v1"); err != nil {
+		t.Fatalf("DownloadReleaseLatestFile() error = %v", err)
+	}
+	if content, _ := os.ReadFile(dst); string(content) != "github" {
+		t.Errorf("下载的文件内容 = %q", content)
+	}
+}
+
+func TestNewGithubRepoInfoUsesRepoSources(t *testing.T) {
+	gitea := &GiteaSource{URL: "https://git.example.com"}
+	SetRepoSources(map[string]RepoSource{"ohmyzsh/ohmyzsh": {Source: gitea, Owner: "mirrors", Repo: "ohmyzsh"}})
+	defer SetRepoSources(nil)
+
+	g := NewGithubRepoInfo("ohmyzsh", "ohmyzsh", "", "", newTestLogger())
+	if got, want := g.GetOriginRepoUrl(), "https://git.example.com/mirrors/ohmyzsh.git"; got != want {
+		t.Errorf("GetOriginRepoUrl() = %s, want %s", got, want)
+	}
+	if got := g.sources(); len(got) != 1 || got[0] != "" {
+		t.Errorf("sources() = %v, want 只有直连", got)
+	}
+
+	other := NewGithubRepoInfo("amix", "vimrc", "", "https://ghproxy.example/", newTestLogger())
+	if got, want := other.GetOriginRepoUrl(), "https://github.com/amix/vimrc.git"; got != want {
+		t.Errorf("GetOriginRepoUrl() = %s, want %s", got, want)
+	}
+	if got := other.sources(); len(got) < 2 || got[0] != "https://ghproxy.example/" {
+		t.Errorf("sources() = %v, want 代理在最前", got)
+	}
+}
+
+func TestGithubEnterpriseSource(t *testing.T) {
+	SetGithubMirrors([]string{"https://mirror.example/"})
+	defer SetGithubMirrors(nil)
+
+	ghe, err := NewSource("github", "https://ghe.example.com", "ghe-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewRepoInfo(ghe, "team", "tool", "", "https://ghproxy.example/", newTestLogger())
+	if got := g.sources(); len(got) != 1 || got[0] != "" {
+		t.Errorf("sources() = %v, want 只有直连", got)
+	}
+	auth, ok := ghe.GitAuth().(*githttp.BasicAuth)
+	if !ok || auth.Password != "ghe-token" {
+		t.Errorf("GitAuth() = %v, want 使用配置的令牌", ghe.GitAuth())
+	}
+
+	// 公共GitHub仍然使用代理和镜像, 且不把令牌发送给它们
+	public := NewRepoInfo(GitHub, "amix", "vimrc", "", "https://ghproxy.example/", newTestLogger())
+	if got := public.sources(); len(got) != 3 || got[0] != "https://ghproxy.example/" || got[2] != "https://mirror.example/" {
+		t.Errorf("sources() = %v, want 代理、直连、镜像", got)
+	}
+	if auth := GitHub.GitAuth(); auth != nil {
+		t.Errorf("GitAuth() = %v, want nil", auth)
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	tests := []struct {
+		sourceType string
+		url        string
+		wantAPI    string
+		wantErr    bool
+	}{
+		{sourceType: "github", wantAPI: "https://api.github.com/repos/o/r/releases/latest"},
+		{sourceType: "github", url: "https://ghe.example.com/", wantAPI: "https://ghe.example.com/api/v3/repos/o/r/releases/latest"},
+		{sourceType: "gitee", wantAPI: "https://gitee.com/api/v5/repos/o/r/releases/latest"},
+		{sourceType: "gitlab", wantAPI: "https://gitlab.com/api/v4/projects/o%2Fr/releases/permalink/latest"},
+		{sourceType: "gitea", url: "https://git.example.com", wantAPI: "https://git.example.com/api/v1/repos/o/r/releases/latest"},
+		{sourceType: "bitbucket", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sourceType+tt.url, func(t *testing.T) {
+			source, err := NewSource(tt.sourceType, tt.url, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("NewSource() 应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewSource() error = %v", err)
+			}
+			if got := source.ReleaseAPI("o", "r", ""); got != tt.wantAPI {
+				t.Errorf("ReleaseAPI() = %s, want %s", got, tt.wantAPI)
+			}
+		})
+	}
+}
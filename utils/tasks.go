@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Task 是一个可以与其他任务并发执行的操作, Deps 中的任务全部成功后才会开始。
+// Deps 中不属于同一批任务的名称会被忽略
+type Task struct {
+	Name string
+	Deps []string
+	Run  func(logger *logrus.Logger) error
+}
+
+type taskResult struct {
+	name string
+	err  error
+}
+
+// RunTasks 按依赖关系执行任务, 同时最多执行jobs个。
+// 并发执行时每个任务的日志先写入独立的缓冲区, 任务结束后整体输出, 避免输出交错;
+// 某个任务失败后, 依赖它的任务会被跳过, 其余任务继续执行; 因循环依赖无法开始的任务同样作为错误返回
+func RunTasks(tasks []Task, jobs int, logger *logrus.Logger) error {
+	if jobs <= 1 || len(tasks) <= 1 {
+		return runTasksSequentially(tasks, logger)
+	}
+
+	var outputMu sync.Mutex
+	pending := make(map[string]bool, len(tasks))
+	known := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		pending[task.Name] = true
+		known[task.Name] = true
+	}
+	done := make(map[string]error, len(tasks))
+	results := make(chan taskResult)
+	running := 0
+	var failed []string
+
+	for len(pending) > 0 || running > 0 {
+		// 启动所有依赖已完成的任务
+		for _, task := range tasks {
+			if !pending[task.Name] || running >= jobs {
+				continue
+			}
+			ready, blockedBy := true, ""
+			for _, dep := range task.Deps {
+				if !known[dep] {
+					continue
+				}
+				err, finished := done[dep]
+				if !finished {
+					ready = false
+					break
+				}
+				if err != nil {
+					blockedBy = dep
+					break
+				}
+			}
+			if blockedBy != "" {
+				delete(pending, task.Name)
+				done[task.Name] = fmt.Errorf("依赖的任务%s失败", blockedBy)
+				failed = append(failed, task.Name)
+				logger.Errorf("[%s] 依赖的任务%s失败,跳过", task.Name, blockedBy)
+				continue
+			}
+			if !ready {
+				continue
+			}
+
+			delete(pending, task.Name)
+			running++
+			logger.Infof("[%s] 开始执行", task.Name)
+			go func(task Task) {
+				results <- taskResult{name: task.Name, err: runBuffered(task, logger, &outputMu)}
+			}(task)
+		}
+
+		if running == 0 {
+			break
+		}
+		result := <-results
+		running--
+		done[result.name] = result.err
+		if result.err != nil {
+			failed = append(failed, result.name)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("以下任务执行失败: %s", strings.Join(failed, ", "))
+	}
+	if len(pending) > 0 {
+		// 任务之间存在循环依赖时, 剩余的任务永远无法开始
+		var stuck []string
+		for _, task := range tasks {
+			if pending[task.Name] {
+				stuck = append(stuck, task.Name)
+			}
+		}
+		return fmt.Errorf("以下任务的依赖无法满足, 未执行: %s", strings.Join(stuck, ", "))
+	}
+	return nil
+}
+
+func runTasksSequentially(tasks []Task, logger *logrus.Logger) error {
+	for _, task := range tasks {
+		if err := task.Run(logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runBuffered 使用独立的日志缓冲区执行任务, 结束后一次性输出
+func runBuffered(task Task, parent *logrus.Logger, outputMu *sync.Mutex) error {
+	buf := &bytes.Buffer{}
+	logger := logrus.New()
+	logger.SetOutput(buf)
+	logger.SetFormatter(parent.Formatter)
+	logger.SetLevel(parent.GetLevel())
+
+	start := time.Now()
+	err := task.Run(logger)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	fmt.Fprintf(parent.Out, "----- [%s] 输出 -----\n", task.Name)
+	parent.Out.Write(buf.Bytes())
+	if err != nil {
+		parent.Errorf("[%s] 执行失败(耗时%s): %s", task.Name, elapsed, err)
+	} else {
+		parent.Infof("[%s] 执行完成(耗时%s)", task.Name, elapsed)
+	}
+	return err
+}
+
+// OutputWriters 返回外部命令和进度信息的输出位置。
+// 日志写到默认的标准错误时沿用标准输出, 否则与日志写到同一位置, 便于并发任务分别收集
+func OutputWriters(logger *logrus.Logger) (io.Writer, io.Writer) {
+	if logger.Out == os.Stderr {
+		return os.Stdout, os.Stderr
+	}
+	return logger.Out, logger.Out
+}
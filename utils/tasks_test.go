@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func newTestLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// taskRecorder 记录任务的执行顺序
+type taskRecorder struct {
+	mu    sync.Mutex
+	order []string
+}
+
+func (r *taskRecorder) task(name string, err error, deps ...string) Task {
+	return Task{
+		Name: name,
+		Deps: deps,
+		Run: func(logger *logrus.Logger) error {
+			time.Sleep(5 * time.Millisecond)
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.order = append(r.order, name)
+			return err
+		},
+	}
+}
+
+func (r *taskRecorder) ran(name string) bool {
+	for _, item := range r.order {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *taskRecorder) index(name string) int {
+	for i, item := range r.order {
+		if item == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestRunTasks(t *testing.T) {
+	failure := errors.New("失败")
+	tests := []struct {
+		name    string
+		jobs    int
+		tasks   func(r *taskRecorder) []Task
+		ran     []string
+		skipped []string
+		before  [][2]string
+		wantErr string
+	}{
+		{
+			name: "顺序执行",
+			jobs: 1,
+			tasks: func(r *taskRecorder) []Task {
+				return []Task{r.task("a", nil), r.task("b", nil), r.task("c", nil)}
+			},
+			ran:    []string{"a", "b", "c"},
+			before: [][2]string{{"a", "b"}, {"b", "c"}},
+		},
+		{
+			name: "顺序执行时失败后停止",
+			jobs: 1,
+			tasks: func(r *taskRecorder) []Task {
+				return []Task{r.task("a", failure), r.task("b", nil)}
+			},
+			ran:     []string{"a"},
+			skipped: []string{"b"},
+			wantErr: "失败",
+		},
+		{
+			name: "并发执行时依赖先完成",
+			jobs: 4,
+			tasks: func(r *taskRecorder) []Task {
+				return []Task{r.task("plugin", nil, "ohmyzsh"), r.task("ohmyzsh", nil), r.task("theme", nil, "ohmyzsh")}
+			},
+			ran:    []string{"ohmyzsh", "plugin", "theme"},
+			before: [][2]string{{"ohmyzsh", "plugin"}, {"ohmyzsh", "theme"}},
+		},
+		{
+			name: "依赖失败时跳过依赖方, 其余任务继续",
+			jobs: 4,
+			tasks: func(r *taskRecorder) []Task {
+				return []Task{r.task("a", failure), r.task("b", nil, "a"), r.task("c", nil, "b"), r.task("d", nil)}
+			},
+			ran:     []string{"a", "d"},
+			skipped: []string{"b", "c"},
+			wantErr: "以下任务执行失败: a, b, c",
+		},
+		{
+			name: "忽略不属于本批次的依赖",
+			jobs: 2,
+			tasks: func(r *taskRecorder) []Task {
+				return []Task{r.task("a", nil, "other"), r.task("b", nil)}
+			},
+			ran: []string{"a", "b"},
+		},
+		{
+			name: "循环依赖的任务无法执行时返回错误",
+			jobs: 2,
+			tasks: func(r *taskRecorder) []Task {
+				return []Task{r.task("a", nil), r.task("b", nil, "c"), r.task("c", nil, "b")}
+			},
+			ran:     []string{"a"},
+			skipped: []string{"b", "c"},
+			wantErr: "以下任务的依赖无法满足, 未执行: b, c",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &taskRecorder{}
+			err := RunTasks(tt.tasks(r), tt.jobs, newTestLogger())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RunTasks() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("RunTasks() error = %v", err)
+			}
+			for _, name := range tt.ran {
+				if !r.ran(name) {
+					t.Errorf("任务%s未执行, 执行顺序: %v", name, r.order)
+				}
+			}
+			for _, name := range tt.skipped {
+				if r.ran(name) {
+					t.Errorf("任务%s不应执行, 执行顺序: %v", name, r.order)
+				}
+			}
+			for _, pair := range tt.before {
+				if r.index(pair[0]) > r.index(pair[1]) {
+					t.Errorf("任务%s应在%s之前执行, 执行顺序: %v", pair[0], pair[1], r.order)
+				}
+			}
+		})
+	}
+}
+
+func TestRunTasksLimitsConcurrency(t *testing.T) {
+	var running, peak int32
+	var tasks []Task
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		tasks = append(tasks, Task{
+			Name: name,
+			Run: func(logger *logrus.Logger) error {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					old := atomic.LoadInt32(&peak)
+					if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			},
+		})
+	}
+	if err := RunTasks(tasks, 2, newTestLogger()); err != nil {
+		t.Fatalf("RunTasks() error = %v", err)
+	}
+	if peak > 2 {
+		t.Errorf("同时执行的任务数 = %d, want <= 2", peak)
+	}
+}
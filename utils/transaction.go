@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sirupsen/logrus"
+)
+
+type undoStep struct {
+	desc string
+	fn   func() error
+}
+
+// Transaction 记录安装过程中每一步的撤销操作, 失败时按相反顺序撤销,
+// 将二进制、配置文件和shell配置恢复到安装前的状态
+type Transaction struct {
+	name      string
+	isRoot    bool
+	backupDir string
+	undos     []undoStep
+	logger    *logrus.Logger
+}
+
+func NewTransaction(name string, isRoot bool, logger *logrus.Logger) *Transaction {
+	return &Transaction{
+		name:   name,
+		isRoot: isRoot,
+		logger: logger,
+	}
+}
+
+// OnRollback 注册一个撤销操作
+func (t *Transaction) OnRollback(desc string, fn func() error) {
+	t.undos = append(t.undos, undoStep{desc: desc, fn: fn})
+}
+
+// TrackCreated 记录一个即将创建的路径, 回滚时删除它。路径已存在时不做处理
+func (t *Transaction) TrackCreated(path string, isSudo bool) {
+	if IsDryRun() {
+		return
+	}
+	if _, err := os.Lstat(path); err == nil {
+		return
+	}
+	t.OnRollback(fmt.Sprintf("删除新建的%s", path), func() error {
+		cmdStr := GenerateCmd(fmt.Sprintf("rm -rf %s", path), isSudo, t.isRoot)
+		return ExecCmd(cmdStr, t.logger)
+	})
+}
+
+// Backup 备份一个即将被修改的文件, 回滚时恢复原始内容。文件不存在时回滚会删除它
+func (t *Transaction) Backup(path string, isSudo bool) error {
+	if IsDryRun() {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		t.TrackCreated(path, isSudo)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("无法备份%s: 不是普通文件", path)
+	}
+
+	if t.backupDir == "" {
+		dir, err := os.MkdirTemp("", "envsetup-backup-")
+		if err != nil {
+			return fmt.Errorf("创建备份目录失败: %w", err)
+		}
+		t.backupDir = dir
+	}
+	backupPath := filepath.Join(t.backupDir, fmt.Sprintf("%d-%s", len(t.undos), filepath.Base(path)))
+	if err := copyFile(path, backupPath, info.Mode()); err != nil {
+		t.logger.Errorf("备份文件%s失败:%s", path, err)
+		return err
+	}
+	t.logger.Debugf("已备份文件%s到%s", path, backupPath)
+
+	t.OnRollback(fmt.Sprintf("恢复文件%s", path), func() error {
+		cmdStr := GenerateCmd(fmt.Sprintf("cp -p %s %s", backupPath, path), isSudo, t.isRoot)
+		return ExecCmd(cmdStr, t.logger)
+	})
+	return nil
+}
+
+// BackupRepo 记录Git仓库当前的HEAD, 回滚时将仓库重置到该提交并删除新增的文件
+func (t *Transaction) BackupRepo(path string) error {
+	if IsDryRun() {
+		return nil
+	}
+	head, err := GetRepoHead(path)
+	if err != nil {
+		return fmt.Errorf("获取仓库%s的版本失败: %w", path, err)
+	}
+	t.logger.Debugf("已记录仓库%s的版本%s", path, head)
+
+	t.OnRollback(fmt.Sprintf("重置仓库%s到%s", path, head), func() error {
+		repo, err := git.PlainOpen(path)
+		if err != nil {
+			return err
+		}
+		worktree, err := repo.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := worktree.Reset(&git.ResetOptions{Commit: plumbing.NewHash(head), Mode: git.HardReset}); err != nil {
+			return err
+		}
+		return worktree.Clean(&git.CleanOptions{Dir: true})
+	})
+	return nil
+}
+
+// Rollback 按相反顺序执行所有撤销操作
+func (t *Transaction) Rollback() error {
+	if len(t.undos) == 0 {
+		return nil
+	}
+	t.logger.Warnf("%s操作失败,开始回滚...", t.name)
+
+	var failed []string
+	for i := len(t.undos) - 1; i >= 0; i-- {
+		step := t.undos[i]
+		if err := step.fn(); err != nil {
+			t.logger.Errorf("回滚步骤[%s]失败:%s", step.desc, err)
+			failed = append(failed, step.desc)
+			continue
+		}
+		t.logger.Infof("回滚步骤[%s]成功", step.desc)
+	}
+	t.undos = nil
+
+	if len(failed) > 0 {
+		// 保留备份目录, 方便手动恢复
+		return fmt.Errorf("%s回滚未完成, 失败的步骤: %s, 备份文件保存在: %s", t.name, strings.Join(failed, ", "), t.backupDir)
+	}
+	t.cleanup()
+	t.logger.Infof("%s已回滚到操作前的状态", t.name)
+	return nil
+}
+
+// Commit 确认操作成功, 清理备份文件
+func (t *Transaction) Commit() {
+	t.undos = nil
+	t.cleanup()
+}
+
+func (t *Transaction) cleanup() {
+	if t.backupDir == "" {
+		return
+	}
+	if err := os.RemoveAll(t.backupDir); err != nil {
+		t.logger.Warnf("清理备份目录%s失败:%s", t.backupDir, err)
+	}
+	t.backupDir = ""
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}